@@ -12,6 +12,15 @@ func Upgrade(ctx context.Context) Context {
 	return &c{Context: ctx, trackingID: uuid.New()}
 }
 
+// UpgradeWithID upgrades from the standard's lib context keeping a tracking ID received from another service.
+// A new one is generated when it is empty
+func UpgradeWithID(ctx context.Context, id string) Context {
+	if id == "" {
+		id = uuid.New()
+	}
+	return &c{Context: ctx, trackingID: id}
+}
+
 // Background context
 func Background() Context {
 	return &c{Context: context.Background(), trackingID: uuid.New()}
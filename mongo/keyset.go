@@ -0,0 +1,30 @@
+package mongo
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// KeysetFilter matches the documents that come after the sort key and id in the order of the sort,
+// ascending or descending, or before them when backward is true. The id is used to break ties between equal keys
+func KeysetFilter(field string, key, id interface{}, descending, backward bool) bson.M {
+	op := "$gt"
+	if descending != backward {
+		op = "$lt"
+	}
+	return bson.M{
+		"$or": bson.A{
+			bson.M{field: bson.M{op: key}},
+			bson.M{field: key, "_id": bson.M{op: id}},
+		},
+	}
+}
+
+// KeysetSort sorts by the field and the id in ascending or descending order, or in the opposite order
+// when backward is true. The documents read backward must be reversed before they are displayed
+func KeysetSort(field string, descending, backward bool) bson.D {
+	order := 1
+	if descending != backward {
+		order = -1
+	}
+	return bson.D{{Key: field, Value: order}, {Key: "_id", Value: order}}
+}
@@ -0,0 +1,39 @@
+package mongo_test
+
+import (
+	"testing"
+
+	"github.com/gonzispina/gokit/mongo"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestKeyset(main *testing.T) {
+	filter := func(op string) bson.M {
+		return bson.M{
+			"$or": bson.A{
+				bson.M{"createdAt": bson.M{op: int64(10)}},
+				bson.M{"createdAt": int64(10), "_id": bson.M{op: "abc"}},
+			},
+		}
+	}
+	sort := func(order int) bson.D {
+		return bson.D{{Key: "createdAt", Value: order}, {Key: "_id", Value: order}}
+	}
+
+	main.Run("Ascending keys are read after the cursor", func(t *testing.T) {
+		require.Equal(t, filter("$gt"), mongo.KeysetFilter("createdAt", int64(10), "abc", false, false))
+		require.Equal(t, sort(1), mongo.KeysetSort("createdAt", false, false))
+
+		require.Equal(t, filter("$lt"), mongo.KeysetFilter("createdAt", int64(10), "abc", false, true))
+		require.Equal(t, sort(-1), mongo.KeysetSort("createdAt", false, true))
+	})
+
+	main.Run("Descending keys are read before the cursor", func(t *testing.T) {
+		require.Equal(t, filter("$lt"), mongo.KeysetFilter("createdAt", int64(10), "abc", true, false))
+		require.Equal(t, sort(-1), mongo.KeysetSort("createdAt", true, false))
+
+		require.Equal(t, filter("$gt"), mongo.KeysetFilter("createdAt", int64(10), "abc", true, true))
+		require.Equal(t, sort(1), mongo.KeysetSort("createdAt", true, true))
+	})
+}
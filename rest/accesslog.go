@@ -0,0 +1,144 @@
+package rest
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gonzispina/gokit/logs"
+	"go.uber.org/zap"
+)
+
+// LogLevel of the access log entries
+type LogLevel string
+
+const (
+	// LevelNone doesn't log the entry
+	LevelNone LogLevel = "none"
+	// LevelDebug logs the entry as debug
+	LevelDebug LogLevel = "debug"
+	// LevelInfo logs the entry as info
+	LevelInfo LogLevel = "info"
+	// LevelWarn logs the entry as warning
+	LevelWarn LogLevel = "warn"
+	// LevelError logs the entry as error
+	LevelError LogLevel = "error"
+)
+
+// AccessLogConfig of the access log
+type AccessLogConfig struct {
+	// Levels by status class, i.e. 4 for 4xx. By default 2xx and 3xx are info, 4xx warn and 5xx error
+	Levels map[int]LogLevel
+	// Exclude paths from the log, i.e. health checks. Paths ending in "*" are prefixes
+	Exclude []string
+	// SampleRate is the fraction, between 0 and 1, of the requests under 400 that are logged.
+	// Every request is logged when it is 0
+	SampleRate float64
+}
+
+// WithAccessLog logs an entry for every request with its method, route pattern, status, latency,
+// bytes read and written, user, ip address and error code
+func WithAccessLog(config AccessLogConfig) Option {
+	levels := map[int]LogLevel{1: LevelInfo, 2: LevelInfo, 3: LevelInfo, 4: LevelWarn, 5: LevelError}
+	for class, level := range config.Levels {
+		levels[class] = level
+	}
+	config.Levels = levels
+	return func(o *options) {
+		o.accessLog = &config
+	}
+}
+
+func (c *AccessLogConfig) excluded(path string) bool {
+	for _, e := range c.Exclude {
+		if prefix := strings.TrimSuffix(e, "*"); prefix != e {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == e {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *AccessLogConfig) sampled(status int) bool {
+	if status >= http.StatusBadRequest || c.SampleRate <= 0 || c.SampleRate >= 1 {
+		return true
+	}
+	return rand.Float64() < c.SampleRate
+}
+
+// accessLog writes the entry of a finished request
+func (u *upgrader) accessLog(w *responseWriter, r *Request, body *countingReader, res *Response, start time.Time) {
+	config := u.options.accessLog
+	if config == nil || config.excluded(r.URL.Path) {
+		return
+	}
+
+	status := w.status
+	if w.panicked {
+		// The response may have been cut short
+		status = http.StatusInternalServerError
+	} else if w.hijacked {
+		status = http.StatusSwitchingProtocols
+	} else if !w.wroteHeader {
+		status = http.StatusOK
+	}
+	if !config.sampled(status) {
+		return
+	}
+
+	var route, code string
+	if rctx := chi.RouteContext(r.Request.Context()); rctx != nil {
+		route = rctx.RoutePattern()
+	}
+	if res != nil {
+		code = res.Code
+	}
+	// The body may not be read completely by the handler
+	requestBytes := body.n
+	if r.ContentLength > requestBytes {
+		requestBytes = r.ContentLength
+	}
+
+	fields := []logs.Field{
+		zap.String("method", r.Method),
+		zap.String("route", route),
+		zap.String("path", r.URL.Path),
+		zap.Int("status", status),
+		zap.Duration("latency", time.Since(start)),
+		zap.Int64("requestBytes", requestBytes),
+		zap.Int64("responseBytes", w.written),
+		logs.UserID(r.UserID),
+		zap.String("ip", r.IPAddress),
+		zap.String("code", code),
+	}
+
+	ctx := r.Context()
+	switch config.Levels[status/100] {
+	case LevelDebug:
+		u.logger.Debug(ctx, "Request", fields...)
+	case LevelInfo:
+		u.logger.Info(ctx, "Request", fields...)
+	case LevelWarn:
+		u.logger.Warn(ctx, "Request", fields...)
+	case LevelError:
+		u.logger.Error(ctx, "Request", fields...)
+	}
+}
+
+// countingReader counts the bytes read from the body of a request
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,159 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gonzispina/gokit/context"
+	"github.com/gonzispina/gokit/errors"
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+type entry struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+// recorder is a logger that keeps the entries in memory
+type recorder struct {
+	mu      sync.Mutex
+	entries []entry
+}
+
+func (l *recorder) log(level, msg string, fields []logs.Field) {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry{level: level, msg: msg, fields: enc.Fields})
+}
+
+func (l *recorder) Info(ctx context.Context, msg string, fields ...logs.Field) {
+	l.log("info", msg, fields)
+}
+
+func (l *recorder) Warn(ctx context.Context, msg string, fields ...logs.Field) {
+	l.log("warn", msg, fields)
+}
+
+func (l *recorder) Debug(ctx context.Context, msg string, fields ...logs.Field) {
+	l.log("debug", msg, fields)
+}
+
+func (l *recorder) Error(ctx context.Context, msg string, fields ...logs.Field) {
+	l.log("error", msg, fields)
+}
+
+func (l *recorder) Fatal(ctx context.Context, msg string, fields ...logs.Field) {
+	l.log("fatal", msg, fields)
+}
+
+func (l *recorder) requests() []entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var res []entry
+	for _, e := range l.entries {
+		if e.msg == "Request" {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// panicReader panics once the response started
+type panicReader struct{}
+
+func (panicReader) Read([]byte) (int, error) {
+	panic("connection reset")
+}
+
+func TestWithAccessLog(main *testing.T) {
+	newRouter := func(logger logs.Logger, config rest.AccessLogConfig) *rest.Router {
+		router := rest.NewRouter(logger, rest.WithAccessLog(config))
+		router.Post("/orders/{orderId}", func(r *rest.Request) *rest.Response {
+			return rest.OK(map[string]string{"id": r.URLParam("orderId")})
+		})
+		router.Get("/orders/{orderId}", func(r *rest.Request) *rest.Response {
+			return rest.NotFound(errors.New("order not found", "order_not_found"))
+		})
+		router.Get("/health", func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		})
+		router.Get("/reports/{reportId}", func(r *rest.Request) *rest.Response {
+			if r.URLParam("reportId") == "1" {
+				panic("report not generated")
+			}
+			return rest.OK(panicReader{})
+		})
+		return router
+	}
+
+	main.Run("An entry is logged for every request", func(t *testing.T) {
+		logger := &recorder{}
+		router := newRouter(logger, rest.AccessLogConfig{Levels: map[int]rest.LogLevel{2: rest.LevelDebug}})
+
+		req := httptest.NewRequest(http.MethodPost, "/orders/1", strings.NewReader(`{"a":1}`))
+		req.Header.Set(rest.CallerIDHeader, "user-1")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders/2", nil))
+
+		entries := logger.requests()
+		require.Len(t, entries, 2)
+
+		require.Equal(t, "debug", entries[0].level)
+		fields := entries[0].fields
+		require.Equal(t, "POST", fields["method"])
+		require.Equal(t, "/orders/{orderId}", fields["route"])
+		require.Equal(t, int64(200), fields["status"])
+		require.Equal(t, int64(7), fields["requestBytes"])
+		require.Equal(t, int64(len(`{"id":"1"}`)+1), fields["responseBytes"])
+		require.Equal(t, "user-1", fields["userId"])
+		require.Equal(t, "192.0.2.1", fields["ip"])
+		require.Contains(t, fields, "latency")
+
+		require.Equal(t, "warn", entries[1].level)
+		require.Equal(t, int64(404), entries[1].fields["status"])
+		require.Equal(t, "order_not_found", entries[1].fields["code"])
+	})
+
+	main.Run("Panics are logged as internal server errors", func(t *testing.T) {
+		logger := &recorder{}
+		router := newRouter(logger, rest.AccessLogConfig{})
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/reports/1", nil))
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/reports/2", nil))
+
+		entries := logger.requests()
+		require.Len(t, entries, 2)
+		for _, e := range entries {
+			require.Equal(t, "error", e.level)
+			require.Equal(t, int64(500), e.fields["status"])
+			require.Equal(t, errors.ErrUnknown.Code(), e.fields["code"])
+		}
+	})
+
+	main.Run("Excluded paths are not logged", func(t *testing.T) {
+		logger := &recorder{}
+		router := newRouter(logger, rest.AccessLogConfig{Exclude: []string{"/health"}})
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+		require.Empty(t, logger.requests())
+	})
+
+	main.Run("Sampling only drops requests under 400", func(t *testing.T) {
+		logger := &recorder{}
+		router := newRouter(logger, rest.AccessLogConfig{SampleRate: 0.000001})
+		for i := 0; i < 10; i++ {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+		}
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders/1", nil))
+		require.Len(t, logger.requests(), 1)
+	})
+}
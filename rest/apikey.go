@@ -0,0 +1,215 @@
+package rest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gonzispina/gokit/context"
+	"github.com/gonzispina/gokit/errors"
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/uuid"
+)
+
+var (
+	// ErrAPIKeyNotFound is returned by the key stores when the key doesn't exist
+	ErrAPIKeyNotFound = errors.New("api key not found", "api_key_not_found")
+	// ErrInvalidAPIKey when the api key of a request is missing, unknown, expired or doesn't match
+	ErrInvalidAPIKey = errors.New("invalid api key", "invalid_api_key")
+)
+
+const apiKeySecretSize = 32
+
+// APIKey stored for a caller. Only a salted hash of the secret is kept
+type APIKey struct {
+	ID        string    `json:"id" bson:"_id"`
+	OwnerID   string    `json:"ownerId" bson:"ownerId"`
+	Name      string    `json:"name" bson:"name"`
+	Scopes    []string  `json:"scopes" bson:"scopes"`
+	Salt      []byte    `json:"-" bson:"salt"`
+	Hash      []byte    `json:"-" bson:"hash"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	// ExpiresAt is zero for keys that don't expire
+	ExpiresAt time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+}
+
+// Expired tells whether the key is expired at the given time
+func (k *APIKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+func (k *APIKey) matches(secret string) bool {
+	return subtle.ConstantTimeCompare(hashAPIKeySecret(k.Salt, secret), k.Hash) == 1
+}
+
+// KeyStore persists the api keys
+type KeyStore interface {
+	// Save creates or replaces the key
+	Save(ctx context.Context, key *APIKey) error
+	// Find the key with the id. It returns ErrAPIKeyNotFound if it doesn't exist
+	Find(ctx context.Context, id string) (*APIKey, error)
+	// Delete the key with the id, revoking it
+	Delete(ctx context.Context, id string) error
+}
+
+// APIKeyOptions of a new key
+type APIKeyOptions struct {
+	OwnerID string
+	Name    string
+	Scopes  []string
+	// TTL of the key, it doesn't expire when it is 0
+	TTL time.Duration
+}
+
+// IssueAPIKey creates and saves a new key. The plaintext key is only returned here,
+// it cannot be recovered later
+func IssueAPIKey(ctx context.Context, store KeyStore, opts APIKeyOptions) (string, *APIKey, error) {
+	secretBytes := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", nil, err
+	}
+
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	now := time.Now().UTC()
+	key := &APIKey{
+		ID:        strings.ReplaceAll(uuid.New(), "-", ""),
+		OwnerID:   opts.OwnerID,
+		Name:      opts.Name,
+		Scopes:    opts.Scopes,
+		Salt:      salt,
+		Hash:      hashAPIKeySecret(salt, secret),
+		CreatedAt: now,
+	}
+	if opts.TTL > 0 {
+		key.ExpiresAt = now.Add(opts.TTL)
+	}
+
+	if err := store.Save(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return key.ID + "." + secret, key, nil
+}
+
+// RotateAPIKey issues a new key with the owner, name and scopes of an existing one. The old key
+// keeps working for the grace period so the callers can be updated. The new key lasts ttl, 0 for no expiry
+func RotateAPIKey(ctx context.Context, store KeyStore, id string, grace, ttl time.Duration) (string, *APIKey, error) {
+	old, err := store.Find(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+
+	plaintext, key, err := IssueAPIKey(ctx, store, APIKeyOptions{OwnerID: old.OwnerID, Name: old.Name, Scopes: old.Scopes, TTL: ttl})
+	if err != nil {
+		return "", nil, err
+	}
+
+	expiresAt := time.Now().UTC().Add(grace)
+	if old.ExpiresAt.IsZero() || expiresAt.Before(old.ExpiresAt) {
+		old.ExpiresAt = expiresAt
+		if err := store.Save(ctx, old); err != nil {
+			return "", nil, err
+		}
+	}
+	return plaintext, key, nil
+}
+
+func hashAPIKeySecret(salt []byte, secret string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(secret))
+	return h.Sum(nil)
+}
+
+// APIKeyConfig of the api key authentication
+type APIKeyConfig struct {
+	// Header with the key, X-API-Key by default
+	Header string
+	// QueryParam with the key, used when the header is not present. Disabled when it is empty
+	// because the urls tend to end up in logs
+	QueryParam string
+}
+
+// APIKeyMiddleware authenticates the requests with an api key issued with IssueAPIKey. The UserID of the
+// request is the owner of the key and its scopes are left in Request.Claims, so Authorize can check them.
+// It returns 401 Unauthorized if the key is missing, unknown or expired
+func APIKeyMiddleware(logger logs.Logger, store KeyStore, config APIKeyConfig) Middleware {
+	if config.Header == "" {
+		config.Header = APIKeyHeader
+	}
+
+	return func(handler HandlerFunc) HandlerFunc {
+		return func(r *Request) *Response {
+			plaintext := r.Header.Get(config.Header)
+			if plaintext == "" && config.QueryParam != "" {
+				plaintext = r.QueryParam(config.QueryParam)
+			}
+
+			id, secret, found := strings.Cut(plaintext, ".")
+			if !found || id == "" || secret == "" {
+				return Unauthorized(ErrInvalidAPIKey)
+			}
+
+			key, err := store.Find(r.Context(), id)
+			if err != nil {
+				if errors.Is(err, ErrAPIKeyNotFound) {
+					return Unauthorized(ErrInvalidAPIKey)
+				}
+				logger.Error(r.Context(), "Couldn't find api key", logs.Error(err))
+				return InternalServerError()
+			}
+			if !key.matches(secret) || key.Expired(time.Now()) {
+				return Unauthorized(ErrInvalidAPIKey)
+			}
+
+			r.UserID = key.OwnerID
+			r.Claims = Claims{"sub": key.OwnerID, "scope": strings.Join(key.Scopes, " "), "kid": key.ID}
+			return handler(r)
+		}
+	}
+}
+
+// MemoryKeyStore keeps the keys in memory, for tests and single instance services
+type MemoryKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]APIKey
+}
+
+// NewMemoryKeyStore returns an empty store
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{keys: map[string]APIKey{}}
+}
+
+// Save creates or replaces the key
+func (s *MemoryKeyStore) Save(ctx context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = *key
+	return nil
+}
+
+// Find the key with the id
+func (s *MemoryKeyStore) Find(ctx context.Context, id string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return &key, nil
+}
+
+// Delete the key with the id
+func (s *MemoryKeyStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, id)
+	return nil
+}
@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"github.com/gonzispina/gokit/context"
+	"github.com/gonzispina/gokit/errors"
+	"github.com/gonzispina/gokit/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	mongooptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoKeyStore keeps the keys in a mongo collection
+type MongoKeyStore struct {
+	mongo      *mongo.Mongo
+	collection string
+}
+
+// NewMongoKeyStore for the collection
+func NewMongoKeyStore(m *mongo.Mongo, collection string) *MongoKeyStore {
+	return &MongoKeyStore{mongo: m, collection: collection}
+}
+
+// Collection definition with the indexes of the store, to be created with mongo.CreateIndexes
+func (s *MongoKeyStore) Collection() mongo.Collection {
+	return mongo.Collection{
+		Name: s.collection,
+		Indexes: []mongo.IndexModel{
+			{Keys: bson.D{{Key: "ownerId", Value: 1}}},
+		},
+	}
+}
+
+// Save creates or replaces the key
+func (s *MongoKeyStore) Save(ctx context.Context, key *APIKey) error {
+	_, err := s.mongo.Collection(s.collection).ReplaceOne(ctx, bson.M{"_id": key.ID}, key, mongooptions.Replace().SetUpsert(true))
+	return err
+}
+
+// Find the key with the id
+func (s *MongoKeyStore) Find(ctx context.Context, id string) (*APIKey, error) {
+	var key APIKey
+	err := s.mongo.Collection(s.collection).FindOne(ctx, bson.M{"_id": id}).Decode(&key)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Delete the key with the id
+func (s *MongoKeyStore) Delete(ctx context.Context, id string) error {
+	_, err := s.mongo.Collection(s.collection).DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
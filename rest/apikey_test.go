@@ -0,0 +1,106 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gonzispina/gokit/context"
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyMiddleware(main *testing.T) {
+	ctx := context.Background()
+	store := rest.NewMemoryKeyStore()
+
+	router := rest.NewRouter(logs.InitTest())
+	router.Use(rest.APIKeyMiddleware(logs.InitTest(), store, rest.APIKeyConfig{QueryParam: "apiKey"}))
+	router.Require(rest.Requirement{Scopes: []string{"orders:read"}}).Get("/orders", func(r *rest.Request) *rest.Response {
+		return rest.OK(r.UserID)
+	})
+
+	do := func(header, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if header != "" {
+			req.Header.Set(rest.APIKeyHeader, header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	main.Run("Only the hash of the key is stored", func(t *testing.T) {
+		plaintext, key, err := rest.IssueAPIKey(ctx, store, rest.APIKeyOptions{OwnerID: "owner", Scopes: []string{"orders:read"}})
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(plaintext, key.ID+"."))
+
+		stored, err := store.Find(ctx, key.ID)
+		require.NoError(t, err)
+		require.NotContains(t, string(stored.Hash), strings.TrimPrefix(plaintext, key.ID+"."))
+		require.Len(t, stored.Salt, 16)
+	})
+
+	main.Run("The key authenticates the owner", func(t *testing.T) {
+		plaintext, _, err := rest.IssueAPIKey(ctx, store, rest.APIKeyOptions{OwnerID: "owner", Scopes: []string{"orders:read"}})
+		require.NoError(t, err)
+
+		w := do(plaintext, "/orders")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), "owner")
+
+		require.Equal(t, http.StatusOK, do("", "/orders?apiKey="+plaintext).Code)
+	})
+
+	main.Run("Invalid keys are unauthorized", func(t *testing.T) {
+		plaintext, key, err := rest.IssueAPIKey(ctx, store, rest.APIKeyOptions{OwnerID: "owner", Scopes: []string{"orders:read"}})
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusUnauthorized, do("", "/orders").Code)
+		require.Equal(t, http.StatusUnauthorized, do("unknown.secret", "/orders").Code)
+		require.Equal(t, http.StatusUnauthorized, do(key.ID+".wrong", "/orders").Code)
+
+		require.NoError(t, store.Delete(ctx, key.ID))
+		w := do(plaintext, "/orders")
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.Contains(t, w.Body.String(), "invalid_api_key")
+	})
+
+	main.Run("Expired keys are unauthorized", func(t *testing.T) {
+		plaintext, key, err := rest.IssueAPIKey(ctx, store, rest.APIKeyOptions{OwnerID: "owner", Scopes: []string{"orders:read"}, TTL: time.Hour})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, do(plaintext, "/orders").Code)
+
+		key.ExpiresAt = time.Now().Add(-time.Second)
+		require.NoError(t, store.Save(ctx, key))
+		require.Equal(t, http.StatusUnauthorized, do(plaintext, "/orders").Code)
+	})
+
+	main.Run("The scopes of the key are authorized", func(t *testing.T) {
+		plaintext, _, err := rest.IssueAPIKey(ctx, store, rest.APIKeyOptions{OwnerID: "owner", Scopes: []string{"orders:write"}})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, do(plaintext, "/orders").Code)
+	})
+
+	main.Run("Rotated keys keep working during the grace period", func(t *testing.T) {
+		old, key, err := rest.IssueAPIKey(ctx, store, rest.APIKeyOptions{OwnerID: "owner", Scopes: []string{"orders:read"}})
+		require.NoError(t, err)
+
+		rotated, newKey, err := rest.RotateAPIKey(ctx, store, key.ID, time.Hour, 0)
+		require.NoError(t, err)
+		require.NotEqual(t, key.ID, newKey.ID)
+		require.Equal(t, key.Scopes, newKey.Scopes)
+		require.Equal(t, http.StatusOK, do(old, "/orders").Code)
+		require.Equal(t, http.StatusOK, do(rotated, "/orders").Code)
+
+		_, _, err = rest.RotateAPIKey(ctx, store, newKey.ID, 0, 0)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusUnauthorized, do(rotated, "/orders").Code)
+
+		_, _, err = rest.RotateAPIKey(ctx, store, "unknown", 0, 0)
+		require.ErrorIs(t, err, rest.ErrAPIKeyNotFound)
+	})
+}
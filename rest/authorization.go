@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gonzispina/gokit/errors"
+)
+
+var (
+	// ErrUnauthenticated when a route that requires authorization is called without credentials
+	ErrUnauthenticated = errors.New("authentication is required", "unauthenticated")
+	// ErrForbidden when the policy of a route denies the request without a reason
+	ErrForbidden = errors.New("the request is not allowed", "forbidden")
+)
+
+// ErrInsufficientScope error
+func ErrInsufficientScope(scope string) error {
+	return errors.New(fmt.Sprintf("the '%s' scope is required", scope), "insufficient_scope")
+}
+
+// ErrInsufficientRole error
+func ErrInsufficientRole(roles []string) error {
+	return errors.New(fmt.Sprintf("one of the roles '%s' is required", strings.Join(roles, "', '")), "insufficient_role")
+}
+
+// Requirement to call a route. Every field that is set must be satisfied
+type Requirement struct {
+	// Scopes the caller must have, all of them. They are read from the "scope" (space separated) or "scp" claims
+	Scopes []string `json:"scopes,omitempty"`
+	// Roles the caller must have, any of them. They are read from the "roles" claim
+	Roles []string `json:"roles,omitempty"`
+	// Policy decides over the request. The request is forbidden when it returns an error
+	Policy func(r *Request) error `json:"-"`
+	// Description of the requirement, used to document the policy
+	Description string `json:"description,omitempty"`
+}
+
+// Scopes of the caller
+func (c Claims) Scopes() []string {
+	if scope := c.String("scope"); scope != "" {
+		return strings.Fields(scope)
+	}
+	return c.Strings("scp")
+}
+
+// Roles of the caller
+func (c Claims) Roles() []string {
+	return c.Strings("roles")
+}
+
+// Authenticated tells whether the credentials of the request were verified by an authentication
+// middleware like JWTMiddleware or APIKeyMiddleware, which leave the claims in Request.Claims.
+// The Caller-ID header alone doesn't authenticate a request, the client can send any user
+func (r *Request) Authenticated() bool {
+	return r.Claims != nil
+}
+
+// Authorize checks the requirement before calling the handler. It returns 401 Unauthorized
+// if the request is not authenticated and 403 Forbidden if it does not satisfy the requirement.
+// It needs an authentication middleware like JWTMiddleware or APIKeyMiddleware
+func Authorize(requirement Requirement) Middleware {
+	return func(handler HandlerFunc) HandlerFunc {
+		return func(r *Request) *Response {
+			if err := requirement.check(r); err != nil {
+				if err == ErrUnauthenticated {
+					return Unauthorized(err)
+				}
+				return Forbidden(err)
+			}
+			return handler(r)
+		}
+	}
+}
+
+func (req Requirement) check(r *Request) error {
+	if !r.Authenticated() {
+		return ErrUnauthenticated
+	}
+
+	scopes := r.Claims.Scopes()
+	for _, scope := range req.Scopes {
+		if !contains(scopes, scope) {
+			return ErrInsufficientScope(scope)
+		}
+	}
+
+	if len(req.Roles) > 0 {
+		roles := r.Claims.Roles()
+		found := false
+		for _, role := range req.Roles {
+			if contains(roles, role) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrInsufficientRole(req.Roles)
+		}
+	}
+
+	if req.Policy != nil {
+		if err := req.Policy(r); err != nil {
+			if _, ok := err.(errors.Error); !ok {
+				return ErrForbidden.Wrap(err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Route registered in a router
+type Route struct {
+	Method       string        `json:"method"`
+	Pattern      string        `json:"pattern"`
+	Requirements []Requirement `json:"requirements,omitempty"`
+}
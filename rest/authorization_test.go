@@ -0,0 +1,108 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonzispina/gokit/errors"
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(main *testing.T) {
+	// authenticate takes the claims from a header to avoid signing tokens
+	authenticate := func(handler rest.HandlerFunc) rest.HandlerFunc {
+		return func(r *rest.Request) *rest.Response {
+			if claims := r.Header.Get("X-Claims"); claims != "" {
+				require.NoError(main, json.Unmarshal([]byte(claims), &r.Claims))
+			}
+			return handler(r)
+		}
+	}
+	errNotOwner := errors.New("only the owner can see the order", "not_owner")
+
+	router := rest.NewRouter(logs.InitTest())
+	router.Use(authenticate)
+	router.Get("/health", func(r *rest.Request) *rest.Response {
+		return rest.NoContent()
+	})
+	orders := router.Group("/orders").Require(rest.Requirement{Scopes: []string{"orders:read"}})
+	orders.Get("/{orderId}", func(r *rest.Request) *rest.Response {
+		return rest.NoContent()
+	}, rest.Authorize(rest.Requirement{Policy: func(r *rest.Request) error {
+		if r.UserID != "owner" {
+			return errNotOwner
+		}
+		return nil
+	}}))
+	admin := orders.Require(rest.Requirement{Roles: []string{"admin", "support"}, Description: "back office users"})
+	admin.Delete("/{orderId}", func(r *rest.Request) *rest.Response {
+		return rest.NoContent()
+	})
+	router.Get("/reports", func(r *rest.Request) *rest.Response {
+		return rest.NoContent()
+	}, rest.Authorize(rest.Requirement{Policy: func(r *rest.Request) error {
+		return fmt.Errorf("reports are disabled")
+	}}))
+
+	do := func(method, target, userID, claims string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if userID != "" {
+			req.Header.Set(rest.CallerIDHeader, userID)
+		}
+		if claims != "" {
+			req.Header.Set("X-Claims", claims)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	main.Run("Requests without credentials are unauthorized", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/orders/1", "", "").Code)
+		require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/reports", "", "").Code)
+
+		// The Caller-ID header is sent by the client, it doesn't authenticate the request
+		require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/orders/1", "owner", "").Code)
+		require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/reports", "owner", "").Code)
+	})
+
+	main.Run("Requests that don't satisfy the requirements are forbidden", func(t *testing.T) {
+		w := do(http.MethodGet, "/orders/1", "owner", `{"scope":"orders:write"}`)
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Contains(t, w.Body.String(), "insufficient_scope")
+
+		w = do(http.MethodGet, "/orders/1", "other", `{"scope":"orders:read orders:write"}`)
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Contains(t, w.Body.String(), "not_owner")
+
+		w = do(http.MethodDelete, "/orders/1", "owner", `{"scp":["orders:read"],"roles":["customer"]}`)
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Contains(t, w.Body.String(), "insufficient_role")
+
+		w = do(http.MethodGet, "/reports", "owner", `{}`)
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Contains(t, w.Body.String(), `"code":"forbidden"`)
+
+		require.Equal(t, http.StatusForbidden, rest.Forbidden().StatusCode)
+	})
+
+	main.Run("Requests that satisfy the requirements are allowed", func(t *testing.T) {
+		require.Equal(t, http.StatusNoContent, do(http.MethodGet, "/orders/1", "owner", `{"scope":"orders:read"}`).Code)
+		require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/orders/1", "", `{"scope":"orders:read","roles":["support"]}`).Code)
+	})
+
+	main.Run("Routes report their requirements", func(t *testing.T) {
+		routes := router.Routes()
+		require.Len(t, routes, 4)
+		require.Equal(t, rest.Route{Method: http.MethodGet, Pattern: "/health"}, routes[0])
+		require.Equal(t, "/orders/{orderId}", routes[1].Pattern)
+		require.Equal(t, []string{"orders:read"}, routes[1].Requirements[0].Scopes)
+		require.Len(t, routes[2].Requirements, 2)
+		require.Equal(t, "back office users", routes[2].Requirements[1].Description)
+	})
+}
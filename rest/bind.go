@@ -0,0 +1,181 @@
+package rest
+
+import (
+	"encoding"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// Bind fills the fields of dst tagged with `path:"name"`, `query:"name"` and `header:"Name"`
+// with the params of the request and validates the result. dst must be a pointer to a struct.
+// Slices take repeated or comma separated values and times are parsed as RFC 3339
+func Bind(r *Request, dst interface{}, validator *Validator) error {
+	value := reflect.ValueOf(dst)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		panic("bind destination must be a pointer to a struct")
+	}
+
+	if err := bindStruct(r, value.Elem()); err != nil {
+		return err
+	}
+
+	if validator == nil {
+		return nil
+	}
+	if err := validator.Value(value, Languages(r)...); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Params binds the params of the request into a T and calls the handler.
+// It returns 400 Bad request if a param cannot be converted or is not valid
+func Params[T any](validator *Validator, handler func(r *Request, params *T) *Response) HandlerFunc {
+	typeOf[T]()
+	return func(r *Request) *Response {
+		params := new(T)
+		if err := Bind(r, params, validator); err != nil {
+			if err == ErrLib {
+				return InternalServerError()
+			}
+			return BadRequest(err)
+		}
+		return handler(r, params)
+	}
+}
+
+func bindStruct(r *Request, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		field := v.Field(i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := bindStruct(r, field); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		name, values := paramValues(r, f)
+		if len(values) == 0 {
+			continue
+		}
+		if err := setField(field, name, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func paramValues(r *Request, f reflect.StructField) (string, []string) {
+	if name := f.Tag.Get("path"); name != "" {
+		if value := r.URLParam(name); value != "" {
+			return name, []string{value}
+		}
+		return name, nil
+	}
+	if name := f.Tag.Get("query"); name != "" {
+		return name, r.QueryParams(name)
+	}
+	if name := f.Tag.Get("header"); name != "" && r.Request != nil {
+		return name, r.Header.Values(name)
+	}
+	return "", nil
+}
+
+func setField(field reflect.Value, name string, values []string) error {
+	if field.Kind() == reflect.Ptr {
+		value := reflect.New(field.Type().Elem())
+		if err := setField(value.Elem(), name, values); err != nil {
+			return err
+		}
+		field.Set(value)
+		return nil
+	}
+
+	if field.Kind() == reflect.Slice && !reflect.PtrTo(field.Type()).Implements(textUnmarshalerType) {
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), name, strings.TrimSpace(value)); err != nil {
+				return ErrInvalidArrayParam(name)
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setValue(field, name, values[0])
+}
+
+func setValue(field reflect.Value, name, value string) error {
+	switch field.Type() {
+	case timeType:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return ErrInvalidTimeParam(name)
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return ErrInvalidParam(name)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) {
+		if err := field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+			return ErrInvalidParam(name)
+		}
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return ErrInvalidBoolParam(name)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return ErrInvalidNumberParam(name)
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return ErrInvalidNumberParam(name)
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return ErrInvalidNumberParam(name)
+		}
+		field.SetFloat(n)
+	default:
+		return ErrInvalidParam(name)
+	}
+	return nil
+}
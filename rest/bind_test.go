@@ -0,0 +1,90 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return rest.ErrInvalidParam("level")
+	}
+	return nil
+}
+
+type searchParams struct {
+	OrderID  string        `path:"orderId" validate:"required"`
+	Limit    int           `query:"limit" validate:"max=50"`
+	Active   *bool         `query:"active"`
+	Since    time.Time     `query:"since"`
+	Timeout  time.Duration `query:"timeout"`
+	Statuses []string      `query:"status"`
+	Amounts  []float64     `query:"amount"`
+	Level    level         `query:"level"`
+	Country  string        `header:"Country-Currency"`
+}
+
+func TestBind(main *testing.T) {
+	validator := rest.NewValidator()
+
+	newRequest := func(target string) *rest.Request {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set(rest.CountryIDHeader, "ARS")
+		return (&rest.Request{Request: r}).SetURLParam("orderId", "o-1")
+	}
+
+	main.Run("Params are converted into the tagged fields", func(t *testing.T) {
+		var params searchParams
+		r := newRequest("/orders/o-1?limit=10&active=true&since=2022-06-01T10:00:00Z&timeout=5s&status=a&status=b&amount=1.5,2&level=high")
+		require.NoError(t, rest.Bind(r, &params, validator))
+
+		require.Equal(t, "o-1", params.OrderID)
+		require.Equal(t, 10, params.Limit)
+		require.True(t, *params.Active)
+		require.Equal(t, time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC), params.Since)
+		require.Equal(t, 5*time.Second, params.Timeout)
+		require.Equal(t, []string{"a", "b"}, params.Statuses)
+		require.Equal(t, []float64{1.5, 2}, params.Amounts)
+		require.Equal(t, level(2), params.Level)
+		require.Equal(t, "ARS", params.Country)
+	})
+
+	main.Run("Conversion errors use the param errors", func(t *testing.T) {
+		var params searchParams
+		err := rest.Bind(newRequest("/orders/o-1?limit=ten"), &params, validator)
+		require.EqualError(t, err, rest.ErrInvalidNumberParam("limit").Error())
+
+		err = rest.Bind(newRequest("/orders/o-1?active=maybe"), &params, validator)
+		require.EqualError(t, err, rest.ErrInvalidBoolParam("active").Error())
+
+		err = rest.Bind(newRequest("/orders/o-1?amount=1,two"), &params, validator)
+		require.EqualError(t, err, rest.ErrInvalidArrayParam("amount").Error())
+	})
+
+	main.Run("Params returns 400 when the params are not valid", func(t *testing.T) {
+		handler := rest.Params(validator, func(r *rest.Request, params *searchParams) *rest.Response {
+			return rest.OK(params)
+		})
+
+		res := handler(newRequest("/orders/o-1?limit=100"))
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "param_length_over_maximum", res.Code)
+		require.Equal(t, "limit", res.Errors[0].Field)
+
+		res = handler(newRequest("/orders/o-1?limit=5"))
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	})
+}
@@ -0,0 +1,187 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	defaultCORSHeaders = []string{AcceptHeader, AcceptLanguageHeader, ContentTypeHeader, AuthorizationHeader}
+	// corsHeaders defined by this package, always allowed
+	corsHeaders = []string{CallerIDHeader, CountryIDHeader, TimeZoneHeader, LanguageHeader, RequestIDHeader, APIKeyHeader}
+)
+
+// CORSConfig of the cross origin requests
+type CORSConfig struct {
+	// AllowedOrigins can be exact, like "https://app.example.com", have a wildcard, like
+	// "https://*.example.com", or be "*" to allow any origin. "*" cannot be used with AllowCredentials
+	AllowedOrigins []string
+	// AllowedMethods by default GET, HEAD, POST, PUT, PATCH and DELETE
+	AllowedMethods []string
+	// AllowedHeaders by default Accept, Accept-Language, Content-Type and Authorization, "*" allows any header.
+	// The headers of this package, Caller-ID, Country-Currency, Time-Zone, Language, X-Request-ID and X-API-Key,
+	// are always allowed
+	AllowedHeaders []string
+	// ExposedHeaders the browser lets the clients read
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and authorization headers
+	AllowCredentials bool
+	// MaxAge the browsers can cache the preflight response
+	MaxAge time.Duration
+}
+
+type cors struct {
+	config         CORSConfig
+	anyOrigin      bool
+	origins        map[string]bool
+	wildcards      [][2]string
+	methods        map[string]bool
+	anyHeader      bool
+	headers        map[string]bool
+	allowedHeaders string
+	exposedHeaders string
+	allowedMethods string
+}
+
+// CORS handles the cross origin requests: it answers the preflight OPTIONS requests and adds the
+// Access-Control-* headers to the responses of the allowed origins. It is an http middleware because
+// the preflights must be answered before the routing, use it with Router.UseHTTP.
+// It panics if any origin is allowed with credentials, since any site could make authenticated requests
+func CORS(config CORSConfig) func(next http.Handler) http.Handler {
+	if len(config.AllowedMethods) == 0 {
+		config.AllowedMethods = defaultCORSMethods
+	}
+	if len(config.AllowedHeaders) == 0 {
+		config.AllowedHeaders = defaultCORSHeaders
+	}
+
+	c := &cors{
+		config:  config,
+		origins: map[string]bool{},
+		methods: map[string]bool{},
+		headers: map[string]bool{},
+	}
+	for _, origin := range config.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		if origin == "*" {
+			c.anyOrigin = true
+		} else if prefix, suffix, found := strings.Cut(origin, "*"); found {
+			c.wildcards = append(c.wildcards, [2]string{prefix, suffix})
+		} else {
+			c.origins[origin] = true
+		}
+	}
+	if c.anyOrigin && config.AllowCredentials {
+		panic("cors cannot allow any origin with credentials, list the allowed origins instead")
+	}
+	for _, method := range config.AllowedMethods {
+		c.methods[strings.ToUpper(method)] = true
+	}
+
+	var headers []string
+	for _, header := range append(append([]string{}, config.AllowedHeaders...), corsHeaders...) {
+		if header == "*" {
+			c.anyHeader = true
+			continue
+		}
+		header = http.CanonicalHeaderKey(header)
+		if !c.headers[header] {
+			c.headers[header] = true
+			headers = append(headers, header)
+		}
+	}
+	c.allowedHeaders = strings.Join(headers, ", ")
+	c.exposedHeaders = strings.Join(config.ExposedHeaders, ", ")
+	c.allowedMethods = strings.Join(config.AllowedMethods, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get(OriginHeader)
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get(AccessControlRequestMethodHeader) != "" {
+				c.preflight(w, r, origin)
+				return
+			}
+
+			w.Header().Add(VaryHeader, OriginHeader)
+			if c.allowOrigin(origin) {
+				c.setOrigin(w, origin)
+				if c.exposedHeaders != "" {
+					w.Header().Set(AccessControlExposeHeadersHeader, c.exposedHeaders)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// preflight answers the OPTIONS request the browsers make before a cross origin request.
+// It is forbidden if the origin, the method or any of the headers are not allowed
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	header := w.Header()
+	header.Add(VaryHeader, OriginHeader)
+	header.Add(VaryHeader, AccessControlRequestMethodHeader)
+	header.Add(VaryHeader, AccessControlRequestHeadersHeader)
+
+	if !c.allowOrigin(origin) || !c.methods[strings.ToUpper(r.Header.Get(AccessControlRequestMethodHeader))] {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	requested := r.Header.Get(AccessControlRequestHeadersHeader)
+	if !c.anyHeader {
+		for _, h := range strings.Split(requested, ",") {
+			if h = strings.TrimSpace(h); h != "" && !c.headers[http.CanonicalHeaderKey(h)] {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	c.setOrigin(w, origin)
+	header.Set(AccessControlAllowMethodsHeader, c.allowedMethods)
+	if c.anyHeader && requested != "" {
+		header.Set(AccessControlAllowHeadersHeader, requested)
+	} else {
+		header.Set(AccessControlAllowHeadersHeader, c.allowedHeaders)
+	}
+	if c.config.MaxAge > 0 {
+		header.Set(AccessControlMaxAgeHeader, strconv.Itoa(int(c.config.MaxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setOrigin allows the origin
+func (c *cors) setOrigin(w http.ResponseWriter, origin string) {
+	if c.anyOrigin {
+		w.Header().Set(AccessControlAllowOriginHeader, "*")
+	} else {
+		w.Header().Set(AccessControlAllowOriginHeader, origin)
+	}
+	if c.config.AllowCredentials {
+		w.Header().Set(AccessControlAllowCredentialsHeader, "true")
+	}
+}
+
+func (c *cors) allowOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if c.origins[origin] {
+		return true
+	}
+	for _, w := range c.wildcards {
+		if len(origin) > len(w[0])+len(w[1]) && strings.HasPrefix(origin, w[0]) && strings.HasSuffix(origin, w[1]) {
+			return true
+		}
+	}
+	return false
+}
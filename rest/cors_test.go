@@ -0,0 +1,113 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORS(main *testing.T) {
+	newRouter := func(config rest.CORSConfig) *rest.Router {
+		router := rest.NewRouter(logs.InitTest())
+		router.UseHTTP(rest.CORS(config))
+		router.Get("/orders", func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		})
+		return router
+	}
+
+	do := func(router *rest.Router, method, origin string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/orders", nil)
+		if origin != "" {
+			req.Header.Set(rest.OriginHeader, origin)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	router := newRouter(rest.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		ExposedHeaders:   []string{rest.RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+
+	main.Run("Preflights of allowed origins are answered", func(t *testing.T) {
+		w := do(router, http.MethodOptions, "https://app.example.com", map[string]string{
+			rest.AccessControlRequestMethodHeader:  http.MethodPost,
+			rest.AccessControlRequestHeadersHeader: "content-type, caller-id, time-zone, x-request-id, x-api-key",
+		})
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Equal(t, "https://app.example.com", w.Header().Get(rest.AccessControlAllowOriginHeader))
+		require.Equal(t, "GET, POST", w.Header().Get(rest.AccessControlAllowMethodsHeader))
+		require.Contains(t, w.Header().Get(rest.AccessControlAllowHeadersHeader), "Country-Currency")
+		require.Equal(t, "true", w.Header().Get(rest.AccessControlAllowCredentialsHeader))
+		require.Equal(t, "600", w.Header().Get(rest.AccessControlMaxAgeHeader))
+
+		w = do(router, http.MethodOptions, "https://shop.example.org", map[string]string{
+			rest.AccessControlRequestMethodHeader: http.MethodGet,
+		})
+		require.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	main.Run("Preflights that are not allowed are forbidden", func(t *testing.T) {
+		w := do(router, http.MethodOptions, "https://evil.com", map[string]string{
+			rest.AccessControlRequestMethodHeader: http.MethodGet,
+		})
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Empty(t, w.Header().Get(rest.AccessControlAllowOriginHeader))
+
+		require.Equal(t, http.StatusForbidden, do(router, http.MethodOptions, "https://example.org", map[string]string{
+			rest.AccessControlRequestMethodHeader: http.MethodGet,
+		}).Code)
+		require.Equal(t, http.StatusForbidden, do(router, http.MethodOptions, "https://app.example.com", map[string]string{
+			rest.AccessControlRequestMethodHeader: http.MethodDelete,
+		}).Code)
+		require.Equal(t, http.StatusForbidden, do(router, http.MethodOptions, "https://app.example.com", map[string]string{
+			rest.AccessControlRequestMethodHeader:  http.MethodGet,
+			rest.AccessControlRequestHeadersHeader: "X-Custom",
+		}).Code)
+	})
+
+	main.Run("Requests of allowed origins get the headers", func(t *testing.T) {
+		w := do(router, http.MethodGet, "https://app.example.com", nil)
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Equal(t, "https://app.example.com", w.Header().Get(rest.AccessControlAllowOriginHeader))
+		require.Equal(t, rest.RequestIDHeader, w.Header().Get(rest.AccessControlExposeHeadersHeader))
+
+		w = do(router, http.MethodGet, "https://evil.com", nil)
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Empty(t, w.Header().Get(rest.AccessControlAllowOriginHeader))
+
+		w = do(router, http.MethodGet, "", nil)
+		require.Empty(t, w.Header().Get(rest.AccessControlAllowOriginHeader))
+	})
+
+	main.Run("Any origin is allowed with a wildcard", func(t *testing.T) {
+		router := newRouter(rest.CORSConfig{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}})
+
+		w := do(router, http.MethodOptions, "https://any.com", map[string]string{
+			rest.AccessControlRequestMethodHeader:  http.MethodGet,
+			rest.AccessControlRequestHeadersHeader: "X-Custom",
+		})
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Equal(t, "*", w.Header().Get(rest.AccessControlAllowOriginHeader))
+		require.Equal(t, "X-Custom", w.Header().Get(rest.AccessControlAllowHeadersHeader))
+	})
+
+	main.Run("Any origin cannot be allowed with credentials", func(t *testing.T) {
+		require.Panics(t, func() {
+			rest.CORS(rest.CORSConfig{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true})
+		})
+	})
+}
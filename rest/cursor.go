@@ -0,0 +1,221 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/gonzispina/gokit/errors"
+)
+
+// ErrInvalidCursor error
+var ErrInvalidCursor = errors.New("'cursor' is not valid", "invalid_cursor")
+
+// Cursor points to an element of a collection sorted by a key. The clients receive it
+// as an opaque signed string that they send back in the "cursor" query param
+type Cursor struct {
+	Sort     string          `json:"s"`
+	Key      json.RawMessage `json:"k"`
+	ID       string          `json:"i"`
+	Backward bool            `json:"b,omitempty"`
+}
+
+// DecodeKey decodes the value of the sort key into v
+func (c *Cursor) DecodeKey(v interface{}) error {
+	return json.Unmarshal(c.Key, v)
+}
+
+// CursorCodec signs and verifies cursors
+type CursorCodec struct {
+	secret []byte
+}
+
+// NewCursorCodec returns a codec that signs the cursors with HMAC-SHA256
+func NewCursorCodec(secret []byte) *CursorCodec {
+	if len(secret) == 0 {
+		panic("cursor secret cannot be empty")
+	}
+	return &CursorCodec{secret: secret}
+}
+
+// Encode the cursor of the endpoint into an opaque string. The endpoint is signed
+// along with the cursor so it cannot be used in another endpoint
+func (c *CursorCodec) Encode(endpoint string, cursor Cursor) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.sign(endpoint, encoded)), nil
+}
+
+// Decode an opaque string into a cursor of the endpoint. It returns ErrInvalidCursor if the
+// signature does not match or the cursor was issued for another endpoint
+func (c *CursorCodec) Decode(endpoint, s string) (*Cursor, error) {
+	encoded, signature, found := strings.Cut(s, ".")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, c.sign(endpoint, encoded)) {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err = json.Unmarshal(payload, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+func (c *CursorCodec) sign(endpoint, encoded string) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(endpoint))
+	mac.Write([]byte{0})
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// cursorEndpoint identifies the endpoint the cursors of the request belong to:
+// the method and the route pattern, or the path when the request was not routed
+func cursorEndpoint(r *Request) string {
+	if r.Request == nil {
+		return ""
+	}
+	if rctx := chi.RouteContext(r.Request.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return r.Method + " " + rctx.RoutePattern()
+	}
+	if r.URL == nil {
+		return r.Method
+	}
+	return r.Method + " " + r.URL.Path
+}
+
+// CursorEndpointMiddleware adds the cursor and limit query params to the filter of the request.
+// The limit is capped to maxLimit, there is no cap when it is 0.
+// It returns 400 Bad request if the limit is not valid or the cursor was issued for another endpoint or another sort
+func CursorEndpointMiddleware(codec *CursorCodec, sort string, maxLimit int64) Middleware {
+	return func(handler HandlerFunc) HandlerFunc {
+		return func(r *Request) *Response {
+			if r.Filter == nil {
+				r.Filter = &Filter{}
+			}
+
+			limitStr := r.QueryParam("limit")
+			if limitStr != "" {
+				limit, err := strconv.ParseInt(limitStr, 10, 64)
+				if err != nil || limit < 0 {
+					return BadRequest(ErrInvalidNumberParam("limit"))
+				}
+				r.Filter.Limit = limit
+			}
+			if maxLimit > 0 && (r.Filter.Limit == 0 || r.Filter.Limit > maxLimit) {
+				r.Filter.Limit = maxLimit
+			}
+
+			cursorStr := r.QueryParam("cursor")
+			if cursorStr != "" {
+				cursor, err := codec.Decode(cursorEndpoint(r), cursorStr)
+				if err != nil || cursor.Sort != sort {
+					return BadRequest(ErrInvalidCursor)
+				}
+				r.Filter.Cursor = cursor
+			}
+
+			return handler(r)
+		}
+	}
+}
+
+// CursorPaging params
+type CursorPaging struct {
+	Limit int64  `json:"limit"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
+// CursorPage result
+type CursorPage struct {
+	Paging *CursorPaging `json:"paging"`
+	Result interface{}   `json:"result"`
+}
+
+// NewCursorPage builds the response of a page sorted by the sort key. The items must be in the order they are
+// displayed and hasMore tells whether there are more items after them in the direction of the request cursor.
+// The next and prev cursors are added to the paging and to an RFC 8288 Link header
+func NewCursorPage[T any](r *Request, codec *CursorCodec, sort string, items []T, hasMore bool, key func(item T) (interface{}, string)) *Response {
+	var cursor *Cursor
+	var limit int64
+	if r.Filter != nil {
+		cursor = r.Filter.Cursor
+		limit = r.Filter.Limit
+	}
+
+	backward := cursor != nil && cursor.Backward
+	hasNext := (!backward && hasMore) || backward
+	hasPrev := (backward && hasMore) || (!backward && cursor != nil)
+
+	paging := &CursorPaging{Limit: limit}
+	if len(items) > 0 {
+		endpoint := cursorEndpoint(r)
+		var err error
+		if hasNext {
+			if paging.Next, err = encodeCursor(codec, endpoint, sort, items[len(items)-1], false, key); err != nil {
+				return InternalServerError()
+			}
+		}
+		if hasPrev {
+			if paging.Prev, err = encodeCursor(codec, endpoint, sort, items[0], true, key); err != nil {
+				return InternalServerError()
+			}
+		}
+	}
+
+	header := map[string]string{}
+	if link := linkHeader(r, paging); link != "" {
+		header[LinkHeader] = link
+	}
+
+	return NewResponse(http.StatusOK, &CursorPage{Paging: paging, Result: items}, header)
+}
+
+func encodeCursor[T any](codec *CursorCodec, endpoint, sort string, item T, backward bool, key func(item T) (interface{}, string)) (string, error) {
+	value, id := key(item)
+	k, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return codec.Encode(endpoint, Cursor{Sort: sort, Key: k, ID: id, Backward: backward})
+}
+
+func linkHeader(r *Request, paging *CursorPaging) string {
+	if r.Request == nil || r.URL == nil {
+		return ""
+	}
+
+	var links []string
+	for _, l := range []struct{ rel, cursor string }{{"next", paging.Next}, {"prev", paging.Prev}} {
+		if l.cursor == "" {
+			continue
+		}
+		u := url.URL{Path: r.URL.Path}
+		query := r.URL.Query()
+		query.Set("cursor", l.cursor)
+		u.RawQuery = query.Encode()
+		links = append(links, fmt.Sprintf("<%s>; rel=\"%s\"", u.String(), l.rel))
+	}
+	return strings.Join(links, ", ")
+}
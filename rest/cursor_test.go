@@ -0,0 +1,145 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+type feedItem struct {
+	ID        string
+	CreatedAt int64
+}
+
+func TestCursor(main *testing.T) {
+	codec := rest.NewCursorCodec([]byte("secret"))
+	key := func(item feedItem) (interface{}, string) {
+		return item.CreatedAt, item.ID
+	}
+
+	main.Run("Encoded cursors are decoded back", func(t *testing.T) {
+		s, err := codec.Encode("GET /feed", rest.Cursor{Sort: "createdAt", Key: []byte("1700000000000"), ID: "abc"})
+		require.NoError(t, err)
+
+		cursor, err := codec.Decode("GET /feed", s)
+		require.NoError(t, err)
+		require.Equal(t, "createdAt", cursor.Sort)
+		require.Equal(t, "abc", cursor.ID)
+
+		var createdAt int64
+		require.NoError(t, cursor.DecodeKey(&createdAt))
+		require.Equal(t, int64(1700000000000), createdAt)
+	})
+
+	main.Run("Tampered cursors are rejected", func(t *testing.T) {
+		s, err := codec.Encode("GET /feed", rest.Cursor{Sort: "createdAt", Key: []byte("1"), ID: "abc"})
+		require.NoError(t, err)
+
+		other, err := rest.NewCursorCodec([]byte("other")).Encode("GET /feed", rest.Cursor{Sort: "createdAt", Key: []byte("1"), ID: "xyz"})
+		require.NoError(t, err)
+
+		_, err = codec.Decode("GET /feed", other)
+		require.ErrorIs(t, err, rest.ErrInvalidCursor)
+		_, err = codec.Decode("GET /feed", s[:len(s)-2])
+		require.ErrorIs(t, err, rest.ErrInvalidCursor)
+		_, err = codec.Decode("GET /orders", s)
+		require.ErrorIs(t, err, rest.ErrInvalidCursor)
+	})
+
+	main.Run("Pages link to the next and previous pages", func(t *testing.T) {
+		items := []feedItem{{ID: "1", CreatedAt: 10}, {ID: "2", CreatedAt: 20}}
+		handler := rest.CursorEndpointMiddleware(codec, "createdAt", 0)(func(r *rest.Request) *rest.Response {
+			return rest.NewCursorPage(r, codec, "createdAt", items, true, key)
+		})
+
+		r := &rest.Request{Request: httptest.NewRequest(http.MethodGet, "/feed?limit=2", nil), Filter: &rest.Filter{}}
+		r.SetQueryParam("limit", "2")
+		res := handler(r)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		page := res.Data.(*rest.CursorPage)
+		require.Equal(t, int64(2), page.Paging.Limit)
+		require.NotEmpty(t, page.Paging.Next)
+		require.Empty(t, page.Paging.Prev)
+
+		link := regexp.MustCompile(`^<(.+)>; rel="next"$`).FindStringSubmatch(res.Header[rest.LinkHeader])
+		require.Len(t, link, 2)
+		u, err := url.Parse(link[1])
+		require.NoError(t, err)
+		require.Equal(t, "/feed", u.Path)
+		require.Equal(t, "2", u.Query().Get("limit"))
+		require.Equal(t, page.Paging.Next, u.Query().Get("cursor"))
+
+		r = &rest.Request{Request: httptest.NewRequest(http.MethodGet, "/feed", nil), Filter: &rest.Filter{}}
+		r.SetQueryParam("cursor", page.Paging.Next)
+		res = handler(r)
+		page = res.Data.(*rest.CursorPage)
+		require.NotEmpty(t, page.Paging.Next)
+		require.NotEmpty(t, page.Paging.Prev)
+
+		prev, err := codec.Decode("GET /feed", page.Paging.Prev)
+		require.NoError(t, err)
+		require.True(t, prev.Backward)
+		require.Equal(t, "1", prev.ID)
+	})
+
+	main.Run("The limit is capped", func(t *testing.T) {
+		handler := rest.CursorEndpointMiddleware(codec, "createdAt", 50)(func(r *rest.Request) *rest.Response {
+			return rest.OK(r.Filter.Limit)
+		})
+		do := func(limit string) *rest.Response {
+			r := &rest.Request{Request: httptest.NewRequest(http.MethodGet, "/feed", nil), Filter: &rest.Filter{}}
+			if limit != "" {
+				r.SetQueryParam("limit", limit)
+			}
+			return handler(r)
+		}
+
+		require.Equal(t, int64(10), do("10").Data)
+		require.Equal(t, int64(50), do("500").Data)
+		require.Equal(t, int64(50), do("").Data)
+
+		res := do("-1")
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "invalid_param_type", res.Code)
+	})
+
+	main.Run("Invalid cursors are a bad request", func(t *testing.T) {
+		handler := rest.CursorEndpointMiddleware(codec, "createdAt", 0)(func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		})
+
+		r := &rest.Request{Filter: &rest.Filter{}}
+		r.SetQueryParam("cursor", "garbage")
+		res := handler(r)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "invalid_cursor", res.Code)
+	})
+
+	main.Run("Cursors of another sort or endpoint are a bad request", func(t *testing.T) {
+		handler := rest.CursorEndpointMiddleware(codec, "createdAt", 0)(func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		})
+		do := func(target, cursor string) *rest.Response {
+			r := &rest.Request{Request: httptest.NewRequest(http.MethodGet, target, nil), Filter: &rest.Filter{}}
+			r.SetQueryParam("cursor", cursor)
+			return handler(r)
+		}
+
+		byName, err := codec.Encode("GET /feed", rest.Cursor{Sort: "name", Key: []byte(`"a"`), ID: "abc"})
+		require.NoError(t, err)
+		res := do("/feed", byName)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "invalid_cursor", res.Code)
+
+		byDate, err := codec.Encode("GET /feed", rest.Cursor{Sort: "createdAt", Key: []byte("1"), ID: "abc"})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, do("/feed", byDate).StatusCode)
+		require.Equal(t, http.StatusBadRequest, do("/orders", byDate).StatusCode)
+	})
+}
@@ -0,0 +1,379 @@
+package rest
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gonzispina/gokit/errors"
+)
+
+// ErrUnsupportedData is returned by the encoders that cannot write the type of the data, i.e. a map as XML.
+// The next encoder accepted by the client is tried instead. Any other error is an internal error
+var ErrUnsupportedData = errors.New("the data cannot be written in the content type", "unsupported_data")
+
+// Encoder writes the data of a response in a given content type
+type Encoder interface {
+	ContentType() ContentType
+	Encode(w io.Writer, data interface{}) error
+}
+
+// NewEncoder returns an encoder out of a function. It is the hook to register custom encoders.
+// fn must return ErrUnsupportedData if it cannot write the type of the data
+func NewEncoder(contentType ContentType, fn func(w io.Writer, data interface{}) error) Encoder {
+	return &encoderFunc{contentType: contentType, fn: fn}
+}
+
+type encoderFunc struct {
+	contentType ContentType
+	fn          func(w io.Writer, data interface{}) error
+}
+
+// ContentType of the encoder
+func (e *encoderFunc) ContentType() ContentType {
+	return e.contentType
+}
+
+// Encode the data
+func (e *encoderFunc) Encode(w io.Writer, data interface{}) error {
+	return e.fn(w, data)
+}
+
+// Encoders registry. JSON is preferred when the client accepts anything, otherwise the first encoder
+type Encoders struct {
+	encoders []Encoder
+}
+
+// NewEncoders returns a registry with the given encoders
+func NewEncoders(encoders ...Encoder) *Encoders {
+	e := &Encoders{}
+	for _, encoder := range encoders {
+		e.Register(encoder)
+	}
+	return e
+}
+
+// DefaultEncoders returns a registry with JSON, XML, CSV and JSON Lines encoders
+func DefaultEncoders() *Encoders {
+	return NewEncoders(JSONEncoder(), XMLEncoder(), CSVEncoder(), JSONLinesEncoder())
+}
+
+// Register an encoder. It replaces any encoder previously registered for the same content type
+func (e *Encoders) Register(encoder Encoder) {
+	for i, enc := range e.encoders {
+		if enc.ContentType() == encoder.ContentType() {
+			e.encoders[i] = encoder
+			return
+		}
+	}
+	e.encoders = append(e.encoders, encoder)
+}
+
+// Get the encoder registered for the content type
+func (e *Encoders) Get(contentType string) (Encoder, bool) {
+	mediaType := baseMediaType(contentType)
+	for _, enc := range e.encoders {
+		if enc.ContentType().String() == mediaType {
+			return enc, true
+		}
+	}
+	return nil, false
+}
+
+// Negotiate returns the encoder that best matches the Accept header
+func (e *Encoders) Negotiate(accept string) (Encoder, bool) {
+	candidates := e.Candidates(accept)
+	if len(candidates) == 0 {
+		return nil, false
+	}
+	return candidates[0], true
+}
+
+// Candidates returns the encoders accepted by the Accept header, the best match first.
+// The encoders named by the header go before the ones only matched by a wildcard and,
+// among the latter, JSON goes first
+func (e *Encoders) Candidates(accept string) []Encoder {
+	type candidate struct {
+		encoder     Encoder
+		quality     float64
+		specificity int
+	}
+
+	ranges := parseAccept(accept)
+	var candidates []candidate
+	for _, enc := range e.encoders {
+		q, s := ranges.match(enc.ContentType().String())
+		if q > 0 {
+			candidates = append(candidates, candidate{encoder: enc, quality: q, specificity: s})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.quality != b.quality {
+			return a.quality > b.quality
+		}
+		if a.specificity != b.specificity {
+			return a.specificity > b.specificity
+		}
+		return a.encoder.ContentType() == ApplicationJSON && b.encoder.ContentType() != ApplicationJSON
+	})
+
+	res := make([]Encoder, 0, len(candidates))
+	for _, c := range candidates {
+		res = append(res, c.encoder)
+	}
+	return res
+}
+
+// Acceptable tells whether the content type is accepted by the Accept header
+func Acceptable(accept, contentType string) bool {
+	q, _ := parseAccept(accept).match(baseMediaType(contentType))
+	return q > 0
+}
+
+type mediaRange struct {
+	mediaType string
+	subType   string
+	q         float64
+}
+
+type mediaRanges []mediaRange
+
+func parseAccept(accept string) mediaRanges {
+	var ranges mediaRanges
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		t, sub, _ := strings.Cut(mediaType, "/")
+		ranges = append(ranges, mediaRange{mediaType: t, subType: sub, q: q})
+	}
+	return ranges
+}
+
+// match returns the quality of the content type according to its most specific matching range,
+// and how specific that range is: 2 for type/subtype, 1 for type/* and 0 for */*
+func (m mediaRanges) match(contentType string) (float64, int) {
+	if len(m) == 0 {
+		return 1, 0
+	}
+
+	t, sub, _ := strings.Cut(contentType, "/")
+	quality, specificity := 0.0, -1
+	for _, r := range m {
+		var s int
+		switch {
+		case r.mediaType == t && r.subType == sub:
+			s = 2
+		case r.mediaType == t && r.subType == "*":
+			s = 1
+		case r.mediaType == "*" && r.subType == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			quality, specificity = r.q, s
+		}
+	}
+	return quality, specificity
+}
+
+func baseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return mediaType
+}
+
+// JSONEncoder returns an application/json encoder
+func JSONEncoder() Encoder {
+	return NewEncoder(ApplicationJSON, func(w io.Writer, data interface{}) error {
+		return json.NewEncoder(w).Encode(data)
+	})
+}
+
+// JSONLinesEncoder returns an encoder that writes each element of a slice as a JSON document per line
+func JSONLinesEncoder() Encoder {
+	return NewEncoder(ApplicationJSONLines, func(w io.Writer, data interface{}) error {
+		encoder := json.NewEncoder(w)
+		value := reflect.Indirect(reflect.ValueOf(data))
+		if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+			return encoder.Encode(data)
+		}
+		for i := 0; i < value.Len(); i++ {
+			if err := encoder.Encode(value.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type xmlList struct {
+	XMLName xml.Name    `xml:"result"`
+	Items   interface{} `xml:"item"`
+}
+
+// XMLEncoder returns an application/xml encoder. Slices are written as <item> elements of a <result> root
+func XMLEncoder() Encoder {
+	return NewEncoder(ApplicationXML, func(w io.Writer, data interface{}) error {
+		value := reflect.Indirect(reflect.ValueOf(data))
+		if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+			data = xmlList{Items: data}
+		}
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		err := xml.NewEncoder(w).Encode(data)
+		var typeErr *xml.UnsupportedTypeError
+		if errors.As(err, &typeErr) {
+			return ErrUnsupportedData.Wrap(err)
+		}
+		return err
+	})
+}
+
+// CSVMarshaler is implemented by the values that write themselves as CSV records
+type CSVMarshaler interface {
+	MarshalCSV() ([][]string, error)
+}
+
+// CSVEncoder returns a text/csv encoder. It supports CSVMarshaler values, [][]string and slices of structs.
+// The header of a slice of structs is taken from the "csv" tags, falling back to the "json" tags
+func CSVEncoder() Encoder {
+	return NewEncoder(TextCSV, func(w io.Writer, data interface{}) error {
+		records, err := csvRecords(data)
+		if err != nil {
+			return err
+		}
+		writer := csv.NewWriter(w)
+		if err = writer.WriteAll(records); err != nil {
+			return err
+		}
+		return writer.Error()
+	})
+}
+
+func csvRecords(data interface{}) ([][]string, error) {
+	switch d := data.(type) {
+	case CSVMarshaler:
+		return d.MarshalCSV()
+	case [][]string:
+		return d, nil
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(data))
+	if value.Kind() == reflect.Struct {
+		slice := reflect.MakeSlice(reflect.SliceOf(value.Type()), 1, 1)
+		slice.Index(0).Set(value)
+		value = slice
+	}
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return nil, ErrUnsupportedData.Wrap(fmt.Errorf("cannot encode %T as csv", data))
+	}
+
+	elem := value.Type().Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return nil, ErrUnsupportedData.Wrap(fmt.Errorf("cannot encode %T as csv", data))
+	}
+
+	columns := csvColumns(elem)
+	header := make([]string, 0, len(columns))
+	for _, c := range columns {
+		header = append(header, c.name)
+	}
+
+	records := [][]string{header}
+	for i := 0; i < value.Len(); i++ {
+		row := reflect.Indirect(value.Index(i))
+		record := make([]string, len(columns))
+		if row.IsValid() {
+			for j, c := range columns {
+				record[j] = csvValue(row.FieldByIndex(c.index))
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+func csvColumns(t reflect.Type) []csvColumn {
+	var columns []csvColumn
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("csv"); ok {
+			name = strings.SplitN(tag, ",", 2)[0]
+		} else if tag, ok := f.Tag.Lookup("json"); ok {
+			if n := strings.SplitN(tag, ",", 2)[0]; n != "" {
+				name = n
+			}
+		}
+		if name == "-" {
+			continue
+		}
+		columns = append(columns, csvColumn{name: name, index: f.Index})
+	}
+	return columns
+}
+
+func csvValue(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Time:
+		return value.Format(time.RFC3339)
+	case encoding.TextMarshaler:
+		text, err := value.MarshalText()
+		if err != nil {
+			return ""
+		}
+		return string(text)
+	case fmt.Stringer:
+		return value.String()
+	}
+
+	return fmt.Sprint(v.Interface())
+}
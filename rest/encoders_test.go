@@ -0,0 +1,129 @@
+package rest_test
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+type product struct {
+	ID    string  `json:"id" xml:"id"`
+	Name  string  `json:"name" xml:"name"`
+	Price float64 `json:"price" xml:"price" csv:"unit_price"`
+}
+
+func TestEncoders(main *testing.T) {
+	products := []product{{ID: "1", Name: "book", Price: 10.5}, {ID: "2", Name: "pen", Price: 1}}
+
+	router := rest.NewRouter(logs.InitTest())
+	router.Get("/products", func(r *rest.Request) *rest.Response {
+		return rest.OK(products)
+	})
+	router.Get("/totals", func(r *rest.Request) *rest.Response {
+		return rest.OK(map[string]int{"products": len(products)})
+	})
+	router.Get("/summary", func(r *rest.Request) *rest.Response {
+		return rest.OK(struct {
+			Count int `json:"count"`
+		}{Count: len(products)})
+	})
+	router.Get("/prices", func(r *rest.Request) *rest.Response {
+		return rest.OK([]product{{ID: "1", Price: math.NaN()}})
+	})
+	router.Get("/products.csv", func(r *rest.Request) *rest.Response {
+		return rest.NewResponse(http.StatusOK, products, map[string]string{rest.ContentTypeHeader: rest.TextCSV.String()})
+	})
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set(rest.AcceptHeader, accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	main.Run("JSON is used when the client accepts anything", func(t *testing.T) {
+		w := get("/products", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/json", w.Header().Get(rest.ContentTypeHeader))
+		require.JSONEq(t, `[{"id":"1","name":"book","price":10.5},{"id":"2","name":"pen","price":1}]`, w.Body.String())
+	})
+
+	main.Run("The encoder is chosen from the Accept header", func(t *testing.T) {
+		w := get("/products", "application/json;q=0.5, text/csv")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "text/csv", w.Header().Get(rest.ContentTypeHeader))
+		require.Equal(t, "id,name,unit_price\n1,book,10.5\n2,pen,1\n", w.Body.String())
+
+		w = get("/products", "application/xml")
+		require.Equal(t, "application/xml", w.Header().Get(rest.ContentTypeHeader))
+		require.Contains(t, w.Body.String(), "<result><item><id>1</id><name>book</name><price>10.5</price></item>")
+
+		w = get("/products", "application/jsonl")
+		require.Equal(t, "{\"id\":\"1\",\"name\":\"book\",\"price\":10.5}\n{\"id\":\"2\",\"name\":\"pen\",\"price\":1}\n", w.Body.String())
+	})
+
+	main.Run("406 is returned when nothing matches", func(t *testing.T) {
+		w := get("/products", "image/png")
+		require.Equal(t, http.StatusNotAcceptable, w.Code)
+
+		w = get("/products.csv", "application/json")
+		require.Equal(t, http.StatusNotAcceptable, w.Code)
+	})
+
+	main.Run("The content type set by the handler wins over negotiation", func(t *testing.T) {
+		w := get("/products.csv", "*/*")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "text/csv", w.Header().Get(rest.ContentTypeHeader))
+	})
+
+	main.Run("JSON is preferred over the encoders matched by a wildcard", func(t *testing.T) {
+		w := get("/products", "*/*")
+		require.Equal(t, "application/json", w.Header().Get(rest.ContentTypeHeader))
+
+		w = get("/products", "text/html, */*;q=0.8")
+		require.Equal(t, "application/json", w.Header().Get(rest.ContentTypeHeader))
+	})
+
+	main.Run("Types the preferred encoder doesn't support are written by the next accepted one", func(t *testing.T) {
+		browser := "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+		w := get("/products", browser)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/xml", w.Header().Get(rest.ContentTypeHeader))
+
+		// XML doesn't support maps nor anonymous structs, so the next accepted encoder is used
+		w = get("/totals", browser)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/json", w.Header().Get(rest.ContentTypeHeader))
+		require.JSONEq(t, `{"products":2}`, w.Body.String())
+
+		w = get("/summary", browser)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/json", w.Header().Get(rest.ContentTypeHeader))
+		require.JSONEq(t, `{"count":2}`, w.Body.String())
+	})
+
+	main.Run("Encoding errors are not hidden behind another content type", func(t *testing.T) {
+		w := get("/prices", "")
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Equal(t, "application/json", w.Header().Get(rest.ContentTypeHeader))
+		require.NotContains(t, w.Body.String(), "<")
+
+		w = get("/prices", "application/json, application/xml;q=0.5")
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	main.Run("406 is returned when no accepted encoder can write the data", func(t *testing.T) {
+		w := get("/totals", "text/csv")
+		require.Equal(t, http.StatusNotAcceptable, w.Code)
+		require.Contains(t, w.Body.String(), "not_acceptable")
+	})
+}
@@ -9,6 +9,7 @@ import (
 
 var (
 	errInvalidContentType = errors.New("invalid content type", "invalid_content_type")
+	errNotAcceptable      = errors.New("none of the accepted content types can be produced", "not_acceptable")
 )
 
 // ErrInvalidStringParam error
@@ -31,23 +32,52 @@ func ErrInvalidArrayParam(name string) error {
 	return errors.New(fmt.Sprintf("'%s' is not valid", name), "invalid_param_value")
 }
 
+// ErrInvalidTimeParam error
+func ErrInvalidTimeParam(name string) error {
+	return errors.New(fmt.Sprintf("'%s' must be a valid RFC 3339 date", name), "invalid_param_type")
+}
+
+// ErrInvalidParam error
+func ErrInvalidParam(name string) error {
+	return errors.New(fmt.Sprintf("'%s' is not valid", name), "invalid_param_value")
+}
+
+// ErrInvalidSortParam error
+func ErrInvalidSortParam(name string) error {
+	return errors.New(fmt.Sprintf("'%s' is not a sortable field", name), "invalid_param_value")
+}
+
+// ErrInvalidFilterParam error
+func ErrInvalidFilterParam(name string) error {
+	return errors.New(fmt.Sprintf("'%s' has an invalid filter", name), "invalid_param_value")
+}
+
 // NewError response
 func NewError(statusCode int, err error) *Response {
 	var code string
 	var description string
 
+	var fields []FieldError
+
 	if err != nil {
 		if sigiErr, ok := err.(errors.Error); ok {
 			code = sigiErr.Code()
 			description = err.Error()
 		}
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			fields = validationErr.Fields
+		}
 	}
 
 	return &Response{
 		Data:       nil,
 		StatusCode: statusCode,
+		Header:     map[string]string{},
 		Err:        description,
 		Code:       code,
+		Errors:     fields,
+		err:        err,
 	}
 }
 
@@ -89,19 +119,37 @@ func NotFound(err error) *Response {
 	return NewError(http.StatusNotFound, err)
 }
 
+// NotAcceptable error response
+func NotAcceptable() *Response {
+	return NewError(http.StatusNotAcceptable, errNotAcceptable)
+}
+
 // RequestEntityTooLarge error response
 func RequestEntityTooLarge() *Response {
 	return NewError(http.StatusRequestEntityTooLarge, nil)
 }
 
-// Unauthorized error response
+// Unauthorized error response, for requests without valid credentials
 func Unauthorized(err error) *Response {
-	return NewError(http.StatusForbidden, err)
+	return NewError(http.StatusUnauthorized, err)
+}
+
+// Forbidden error response, for authenticated requests that are not allowed. The reason is optional
+func Forbidden(err ...error) *Response {
+	if len(err) == 0 {
+		return NewError(http.StatusForbidden, nil)
+	}
+	return NewError(http.StatusForbidden, err[0])
+}
+
+// TooManyRequests error response
+func TooManyRequests(err error) *Response {
+	return NewError(http.StatusTooManyRequests, err)
 }
 
-// Forbidden error response
-func Forbidden() *Response {
-	return NewError(http.StatusForbidden, nil)
+// ServiceUnavailable error response
+func ServiceUnavailable(err error) *Response {
+	return NewError(http.StatusServiceUnavailable, err)
 }
 
 // TooEarly error response
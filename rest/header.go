@@ -21,4 +21,54 @@ const (
 	AcceptLanguageHeader = "Accept-Language"
 	// LocationHeader header
 	LocationHeader = "Location"
+	// AcceptHeader header
+	AcceptHeader = "Accept"
+	// VaryHeader header
+	VaryHeader = "Vary"
+	// LinkHeader header
+	LinkHeader = "Link"
+	// CacheControlHeader header
+	CacheControlHeader = "Cache-Control"
+	// ConnectionHeader header
+	ConnectionHeader = "Connection"
+	// RequestIDHeader header
+	RequestIDHeader = "X-Request-ID"
+	// TraceparentHeader header
+	TraceparentHeader = "traceparent"
+	// ForwardedHeader header
+	ForwardedHeader = "Forwarded"
+	// XForwardedForHeader header
+	XForwardedForHeader = "X-Forwarded-For"
+	// XRealIPHeader header
+	XRealIPHeader = "X-Real-IP"
+	// WWWAuthenticateHeader header
+	WWWAuthenticateHeader = "WWW-Authenticate"
+	// APIKeyHeader header
+	APIKeyHeader = "X-API-Key"
+	// RetryAfterHeader header
+	RetryAfterHeader = "Retry-After"
+	// RateLimitLimitHeader header
+	RateLimitLimitHeader = "RateLimit-Limit"
+	// RateLimitRemainingHeader header
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	// RateLimitResetHeader header
+	RateLimitResetHeader = "RateLimit-Reset"
+	// OriginHeader header
+	OriginHeader = "Origin"
+	// AccessControlAllowOriginHeader header
+	AccessControlAllowOriginHeader = "Access-Control-Allow-Origin"
+	// AccessControlAllowMethodsHeader header
+	AccessControlAllowMethodsHeader = "Access-Control-Allow-Methods"
+	// AccessControlAllowHeadersHeader header
+	AccessControlAllowHeadersHeader = "Access-Control-Allow-Headers"
+	// AccessControlAllowCredentialsHeader header
+	AccessControlAllowCredentialsHeader = "Access-Control-Allow-Credentials"
+	// AccessControlExposeHeadersHeader header
+	AccessControlExposeHeadersHeader = "Access-Control-Expose-Headers"
+	// AccessControlMaxAgeHeader header
+	AccessControlMaxAgeHeader = "Access-Control-Max-Age"
+	// AccessControlRequestMethodHeader header
+	AccessControlRequestMethodHeader = "Access-Control-Request-Method"
+	// AccessControlRequestHeadersHeader header
+	AccessControlRequestHeadersHeader = "Access-Control-Request-Headers"
 )
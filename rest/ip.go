@@ -0,0 +1,155 @@
+package rest
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IPResolver resolves the ip address of the client of requests that went through trusted proxies
+type IPResolver struct {
+	trusted []*net.IPNet
+}
+
+// NewIPResolver trusting the proxies in the given CIDRs, i.e. "10.0.0.0/8" or "fd00::/8".
+// Single addresses are accepted too
+func NewIPResolver(cidrs ...string) (*IPResolver, error) {
+	r := &IPResolver{}
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			r.trusted = append(r.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		r.trusted = append(r.trusted, network)
+	}
+	return r, nil
+}
+
+// WithIPResolver resolves the IPAddress of the requests with the resolver.
+// Without it the IPAddress is the remote address of the connection
+func WithIPResolver(resolver *IPResolver) Option {
+	return func(o *options) {
+		o.ipResolver = resolver
+	}
+}
+
+func (r *IPResolver) isTrusted(ip net.IP) bool {
+	for _, network := range r.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP of the request. When the connection comes from a trusted proxy the addresses of the
+// Forwarded header, or X-Forwarded-For if there is none, are walked from the last one and the first
+// one that is not a trusted proxy is the client. X-Real-IP is used when neither header is present
+func (r *IPResolver) ClientIP(req *http.Request) string {
+	remote := parseIP(req.RemoteAddr)
+	if remote == nil {
+		return req.RemoteAddr
+	}
+	if !r.isTrusted(remote) {
+		return remote.String()
+	}
+
+	chain, ok := forwardedFor(req.Header.Values(ForwardedHeader))
+	if !ok {
+		chain, ok = xForwardedFor(req.Header.Values(XForwardedForHeader))
+	}
+	if !ok {
+		if ip := parseIP(req.Header.Get(XRealIPHeader)); ip != nil {
+			return ip.String()
+		}
+		return remote.String()
+	}
+
+	client := remote
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := parseIP(chain[i])
+		if ip == nil {
+			// Unknown or obfuscated hops cannot be trusted, the last known one is used
+			break
+		}
+		client = ip
+		if !r.isTrusted(ip) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// remoteIP of the connection without the port
+func remoteIP(remoteAddr string) string {
+	if ip := parseIP(remoteAddr); ip != nil {
+		return ip.String()
+	}
+	return remoteAddr
+}
+
+// parseIP of an address that may have a port and brackets, i.e. "[2001:db8::1]:8080".
+// IPv4-mapped IPv6 addresses are returned as IPv4
+func parseIP(addr string) net.IP {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	// Zones are not part of the address, i.e. "fe80::1%eth0"
+	if i := strings.IndexByte(addr, '%'); i >= 0 {
+		addr = addr[:i]
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+func xForwardedFor(values []string) ([]string, bool) {
+	var chain []string
+	for _, value := range values {
+		for _, addr := range strings.Split(value, ",") {
+			chain = append(chain, strings.TrimSpace(addr))
+		}
+	}
+	return chain, len(chain) > 0
+}
+
+// forwardedFor returns the "for" parameters of the RFC 7239 Forwarded header, i.e.
+// for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"
+func forwardedFor(values []string) ([]string, bool) {
+	var chain []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			var forValue string
+			for _, pair := range strings.Split(element, ";") {
+				key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "for") {
+					forValue = strings.Trim(value, `"`)
+				}
+			}
+			chain = append(chain, forValue)
+		}
+	}
+	return chain, len(chain) > 0
+}
@@ -0,0 +1,75 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPResolver(main *testing.T) {
+	resolver, err := rest.NewIPResolver("10.0.0.0/8", "fd00::/8", "192.0.2.10")
+	require.NoError(main, err)
+
+	request := func(remoteAddr string, header map[string]string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+
+	main.Run("Headers of untrusted connections are ignored", func(t *testing.T) {
+		ip := resolver.ClientIP(request("203.0.113.5:1234", map[string]string{rest.XForwardedForHeader: "198.51.100.1"}))
+		require.Equal(t, "203.0.113.5", ip)
+	})
+
+	main.Run("X-Forwarded-For is walked until the first untrusted address", func(t *testing.T) {
+		ip := resolver.ClientIP(request("10.0.0.1:1234", map[string]string{rest.XForwardedForHeader: "1.1.1.1, 198.51.100.1, 10.1.1.1"}))
+		require.Equal(t, "198.51.100.1", ip)
+
+		ip = resolver.ClientIP(request("192.0.2.10:1234", map[string]string{rest.XForwardedForHeader: "10.2.2.2"}))
+		require.Equal(t, "10.2.2.2", ip)
+	})
+
+	main.Run("Forwarded takes precedence and supports IPv6", func(t *testing.T) {
+		ip := resolver.ClientIP(request("[fd00::1]:443", map[string]string{
+			rest.ForwardedHeader:     `for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.2`,
+			rest.XForwardedForHeader: "198.51.100.1",
+		}))
+		require.Equal(t, "2001:db8:cafe::17", ip)
+
+		ip = resolver.ClientIP(request("10.0.0.1:1234", map[string]string{rest.ForwardedHeader: "for=unknown, for=10.0.0.2"}))
+		require.Equal(t, "10.0.0.2", ip)
+	})
+
+	main.Run("X-Real-IP is used without forwarding headers", func(t *testing.T) {
+		ip := resolver.ClientIP(request("10.0.0.1:1234", map[string]string{rest.XRealIPHeader: "198.51.100.7"}))
+		require.Equal(t, "198.51.100.7", ip)
+	})
+
+	main.Run("Invalid proxies are rejected", func(t *testing.T) {
+		_, err := rest.NewIPResolver("10.0.0.0/33")
+		require.Error(t, err)
+	})
+
+	main.Run("The resolved address is set in the request", func(t *testing.T) {
+		router := rest.NewRouter(logs.InitTest(), rest.WithIPResolver(resolver))
+		router.Get("/", func(r *rest.Request) *rest.Response {
+			return rest.OK(r.IPAddress)
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request("10.0.0.1:1234", map[string]string{rest.XForwardedForHeader: "2001:db8::1"}))
+		require.JSONEq(t, `"2001:db8::1"`, w.Body.String())
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, request("bad address", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `"bad address"`, w.Body.String())
+	})
+}
@@ -0,0 +1,263 @@
+package rest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/gonzispina/gokit/errors"
+)
+
+var (
+	// ErrMissingToken when the request has no bearer token
+	ErrMissingToken = errors.New("missing bearer token", "missing_token")
+	// ErrInvalidToken when the token cannot be verified
+	ErrInvalidToken = errors.New("invalid token", "invalid_token")
+	// ErrExpiredToken when the token is expired
+	ErrExpiredToken = errors.New("token is expired", "token_expired")
+)
+
+// JWTConfig of the bearer authentication
+type JWTConfig struct {
+	// Keys that verify the signature of the tokens
+	Keys *KeySet
+	// Issuer the tokens must have in the "iss" claim. Not checked when it is empty
+	Issuer string
+	// Audience the tokens must have in the "aud" claim. Not checked when it is empty
+	Audience string
+	// UserIDClaim is the claim used as the UserID of the request, "sub" by default
+	UserIDClaim string
+	// Leeway for the clock skew when checking "exp" and "nbf"
+	Leeway time.Duration
+	// Realm sent in the WWW-Authenticate header
+	Realm string
+}
+
+// Claims of a verified token
+type Claims map[string]interface{}
+
+// String claim, empty if it is not present or it is not a string
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings claim, for the claims that can be a string or an array of strings like "aud"
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		res := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// Time claim, for the NumericDate claims like "exp"
+func (c Claims) Time(name string) (time.Time, bool) {
+	n, ok := c[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(f*float64(time.Second))), true
+}
+
+// Decode the claims into v, a pointer to a struct with json tags
+func (c Claims) Decode(v interface{}) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// ClaimsAs decodes the claims of the request into a T
+func ClaimsAs[T any](r *Request) (*T, error) {
+	if r.Claims == nil {
+		return nil, ErrMissingToken
+	}
+	res := new(T)
+	if err := r.Claims.Decode(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// JWTMiddleware authenticates the requests with the JWT bearer token of the Authorization header.
+// The UserID of the request is taken from the token, instead of the Caller-ID header, and the claims are
+// left in Request.Claims. It returns 401 Unauthorized with a WWW-Authenticate header if the token is
+// missing or it is not valid
+func JWTMiddleware(config JWTConfig) Middleware {
+	if config.Keys == nil || len(config.Keys.keys) == 0 {
+		panic("jwt keys cannot be empty")
+	}
+	if config.UserIDClaim == "" {
+		config.UserIDClaim = "sub"
+	}
+
+	return func(handler HandlerFunc) HandlerFunc {
+		return func(r *Request) *Response {
+			token, ok := bearerToken(r)
+			if !ok {
+				return config.unauthorized(ErrMissingToken)
+			}
+
+			claims, err := config.verify(token, time.Now())
+			if err != nil {
+				return config.unauthorized(err)
+			}
+
+			userID := claims.String(config.UserIDClaim)
+			if userID == "" {
+				return config.unauthorized(ErrInvalidToken)
+			}
+
+			r.UserID = userID
+			r.Claims = claims
+			return handler(r)
+		}
+	}
+}
+
+func bearerToken(r *Request) (string, bool) {
+	if r.Request == nil {
+		return "", false
+	}
+	scheme, token, found := strings.Cut(r.Header.Get(AuthorizationHeader), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// unauthorized response with the challenge defined by RFC 6750
+func (c JWTConfig) unauthorized(err error) *Response {
+	var params []string
+	if c.Realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", c.Realm))
+	}
+	if err != ErrMissingToken {
+		params = append(params, `error="invalid_token"`, fmt.Sprintf("error_description=%q", err.Error()))
+	}
+
+	challenge := "Bearer"
+	if len(params) > 0 {
+		challenge += " " + strings.Join(params, ", ")
+	}
+
+	res := Unauthorized(err)
+	res.Header[WWWAuthenticateHeader] = challenge
+	return res
+}
+
+// verify the token and its registered claims
+func (c JWTConfig) verify(token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, ok := c.Keys.key(header.Kid, header.Alg)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	exp, ok := claims.Time("exp")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if now.After(exp.Add(c.Leeway)) {
+		return nil, ErrExpiredToken
+	}
+	if nbf, ok := claims.Time("nbf"); ok && now.Add(c.Leeway).Before(nbf) {
+		return nil, ErrInvalidToken
+	}
+	if c.Issuer != "" && claims.String("iss") != c.Issuer {
+		return nil, ErrInvalidToken
+	}
+	if c.Audience != "" && !contains(claims.Strings("aud"), c.Audience) {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func verifySignature(alg string, key interface{}, signed, signature []byte) bool {
+	hash := sha256.Sum256(signed)
+	switch alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case AlgRS256:
+		return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, hash[:], signature) == nil
+	case AlgES256:
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key.(*ecdsa.PublicKey), hash[:], r, s)
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
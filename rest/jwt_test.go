@@ -0,0 +1,212 @@
+package rest_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+func signToken(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, hash[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, hash[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+type tokenClaims struct {
+	Subject string   `json:"sub"`
+	Email   string   `json:"email"`
+	Roles   []string `json:"roles"`
+}
+
+func TestJWTMiddleware(main *testing.T) {
+	secret := []byte("a very secret secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(main, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(main, err)
+
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "oct", "kid": "hmac", "k": b64(secret)},
+	}})
+	require.NoError(main, err)
+	path := filepath.Join(main.TempDir(), "jwks.json")
+	require.NoError(main, os.WriteFile(path, jwks, 0o600))
+
+	keys, err := rest.LoadJWKS(path)
+	require.NoError(main, err)
+
+	router := rest.NewRouter(logs.InitTest())
+	router.Use(rest.JWTMiddleware(rest.JWTConfig{Keys: keys, Issuer: "https://auth.example.com", Audience: "orders", UserIDClaim: "uid", Realm: "orders"}))
+	router.Get("/me", func(r *rest.Request) *rest.Response {
+		claims, err := rest.ClaimsAs[tokenClaims](r)
+		require.NoError(main, err)
+		return rest.OK(map[string]interface{}{"userId": r.UserID, "email": claims.Email, "roles": claims.Roles})
+	})
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"sub":   "subject",
+			"uid":   "user-1",
+			"email": "user@example.com",
+			"roles": []string{"admin"},
+			"iss":   "https://auth.example.com",
+			"aud":   []string{"billing", "orders"},
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nbf":   time.Now().Add(-time.Minute).Unix(),
+		}
+	}
+
+	do := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set(rest.CallerIDHeader, "impostor")
+		if token != "" {
+			req.Header.Set(rest.AuthorizationHeader, "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	main.Run("Tokens signed with every algorithm are accepted", func(t *testing.T) {
+		for _, token := range []string{
+			signToken(t, rest.AlgHS256, "hmac", secret, valid()),
+			signToken(t, rest.AlgRS256, "rsa", rsaKey, valid()),
+			signToken(t, rest.AlgES256, "ec", ecKey, valid()),
+		} {
+			w := do(token)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			require.JSONEq(t, `{"userId":"user-1","email":"user@example.com","roles":["admin"]}`, w.Body.String())
+		}
+	})
+
+	main.Run("Missing tokens get a challenge without error", func(t *testing.T) {
+		w := do("")
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.Equal(t, `Bearer realm="orders"`, w.Header().Get(rest.WWWAuthenticateHeader))
+	})
+
+	main.Run("Invalid tokens are rejected", func(t *testing.T) {
+		expired := valid()
+		expired["exp"] = time.Now().Add(-time.Hour).Unix()
+		notYet := valid()
+		notYet["nbf"] = time.Now().Add(time.Hour).Unix()
+		otherAudience := valid()
+		otherAudience["aud"] = "billing"
+		otherIssuer := valid()
+		otherIssuer["iss"] = "https://evil.example.com"
+
+		for name, token := range map[string]string{
+			"expired":          signToken(t, rest.AlgHS256, "hmac", secret, expired),
+			"not yet valid":    signToken(t, rest.AlgHS256, "hmac", secret, notYet),
+			"other audience":   signToken(t, rest.AlgHS256, "hmac", secret, otherAudience),
+			"other issuer":     signToken(t, rest.AlgHS256, "hmac", secret, otherIssuer),
+			"wrong secret":     signToken(t, rest.AlgHS256, "hmac", []byte("other"), valid()),
+			"algorithm switch": signToken(t, rest.AlgHS256, "rsa", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey), valid()),
+			"malformed":        "not.a.token",
+		} {
+			w := do(token)
+			require.Equal(t, http.StatusUnauthorized, w.Code, name)
+			require.Contains(t, w.Header().Get(rest.WWWAuthenticateHeader), `error="invalid_token"`, name)
+		}
+
+		w := do(signToken(t, rest.AlgHS256, "hmac", secret, expired))
+		require.Contains(t, w.Body.String(), "token_expired")
+	})
+
+	main.Run("Keys are loaded from PEM files", func(t *testing.T) {
+		der, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+		require.NoError(t, err)
+		path := filepath.Join(t.TempDir(), "key.pem")
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+		keys, err := rest.LoadPEMKey(path, "")
+		require.NoError(t, err)
+
+		handler := rest.JWTMiddleware(rest.JWTConfig{Keys: keys})(func(r *rest.Request) *rest.Response {
+			return rest.OK(r.UserID)
+		})
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(rest.AuthorizationHeader, "Bearer "+signToken(t, rest.AlgES256, "", ecKey, valid()))
+		res := handler(&rest.Request{Request: r})
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "subject", res.Data)
+	})
+
+	main.Run("Unsupported keys of a JWKS document are skipped", func(t *testing.T) {
+		keys, err := rest.ParseJWKS([]byte(`{"keys":[
+			{"kty":"OKP","kid":"ed","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+			{"kty":"EC","kid":"ec384","crv":"P-384","x":"AA","y":"AA"},
+			{"kty":"oct","kid":"hmac","k":"` + b64(secret) + `"}
+		]}`))
+		require.NoError(t, err)
+
+		handler := rest.JWTMiddleware(rest.JWTConfig{Keys: keys})(func(r *rest.Request) *rest.Response {
+			return rest.OK(r.UserID)
+		})
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(rest.AuthorizationHeader, "Bearer "+signToken(t, rest.AlgHS256, "hmac", secret, valid()))
+		res := handler(&rest.Request{Request: r})
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		_, err = rest.ParseJWKS([]byte(`{"keys":[{"kty":"OKP","kid":"ed","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}]}`))
+		require.Error(t, err)
+	})
+
+	main.Run("Key ids of a JWKS document must be unique", func(t *testing.T) {
+		for _, doc := range []string{
+			`{"keys":[{"kty":"oct","kid":"hmac","k":"YQ"},{"kty":"oct","kid":"hmac","k":"Yg"}]}`,
+			`{"keys":[{"kty":"oct","k":"YQ"},{"kty":"RSA","n":"` + b64(rsaKey.N.Bytes()) + `","e":"AQAB"}]}`,
+		} {
+			_, err := rest.ParseJWKS([]byte(doc))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "duplicate key id")
+		}
+	})
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,211 @@
+package rest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+const (
+	// AlgHS256 is HMAC with SHA-256
+	AlgHS256 = "HS256"
+	// AlgRS256 is RSASSA-PKCS1-v1_5 with SHA-256
+	AlgRS256 = "RS256"
+	// AlgES256 is ECDSA with the P-256 curve and SHA-256
+	AlgES256 = "ES256"
+)
+
+// KeySet with the keys that verify the tokens. The algorithm of a token must be the one of its key,
+// so a token signed with HS256 cannot be verified with the secret of an RSA public key
+type KeySet struct {
+	keys map[string]verificationKey
+}
+
+type verificationKey struct {
+	alg string
+	key interface{}
+}
+
+// NewKeySet without keys
+func NewKeySet() *KeySet {
+	return &KeySet{keys: map[string]verificationKey{}}
+}
+
+// AddHMAC adds an HS256 secret with the given key id. The id can be empty when it is the only key
+func (s *KeySet) AddHMAC(kid string, secret []byte) *KeySet {
+	s.keys[kid] = verificationKey{alg: AlgHS256, key: secret}
+	return s
+}
+
+// AddPublicKey adds an RSA (RS256) or P-256 ECDSA (ES256) public key with the given key id
+func (s *KeySet) AddPublicKey(kid string, key interface{}) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		s.keys[kid] = verificationKey{alg: AlgRS256, key: k}
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return fmt.Errorf("unsupported curve %s", k.Curve.Params().Name)
+		}
+		s.keys[kid] = verificationKey{alg: AlgES256, key: k}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return nil
+}
+
+// key used to verify a token with the given key id and algorithm
+func (s *KeySet) key(kid, alg string) (interface{}, bool) {
+	k, ok := s.keys[kid]
+	if !ok && kid == "" && len(s.keys) == 1 {
+		for _, only := range s.keys {
+			k, ok = only, true
+		}
+	}
+	if !ok || k.alg != alg {
+		return nil, false
+	}
+	return k.key, true
+}
+
+// LoadPEMKey loads an RSA or ECDSA public key, or the one of a certificate, from a PEM file
+func LoadPEMKey(path, kid string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewKeySet()
+	if err := s.AddPublicKey(kid, key); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadJWKS loads the keys of a local JSON Web Key Set document
+func LoadJWKS(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+// ParseJWKS parses a JSON Web Key Set document. RSA, P-256 EC and oct (HMAC) keys are supported,
+// keys of other types and keys used for encryption are skipped. The key ids must be unique
+// and at least one key must be usable
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JWKS document: %w", err)
+	}
+
+	s := NewKeySet()
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if !supportedJWK(k.Kty, k.Crv) {
+			continue
+		}
+		if _, ok := s.keys[k.Kid]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", k.Kid)
+		}
+
+		switch k.Kty {
+		case "RSA":
+			n, err := decodeBigInt(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RSA key %q: %w", k.Kid, err)
+			}
+			e, err := decodeBigInt(k.E)
+			if err != nil || !e.IsInt64() {
+				return nil, fmt.Errorf("invalid RSA key %q", k.Kid)
+			}
+			_ = s.AddPublicKey(k.Kid, &rsa.PublicKey{N: n, E: int(e.Int64())})
+		case "EC":
+			x, err := decodeBigInt(k.X)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EC key %q: %w", k.Kid, err)
+			}
+			y, err := decodeBigInt(k.Y)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EC key %q: %w", k.Kid, err)
+			}
+			if !elliptic.P256().IsOnCurve(x, y) {
+				return nil, fmt.Errorf("invalid EC key %q", k.Kid)
+			}
+			_ = s.AddPublicKey(k.Kid, &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y})
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil {
+				return nil, fmt.Errorf("invalid oct key %q: %w", k.Kid, err)
+			}
+			s.AddHMAC(k.Kid, secret)
+		}
+	}
+	if len(s.keys) == 0 {
+		return nil, fmt.Errorf("the JWKS document has no usable keys")
+	}
+	return s, nil
+}
+
+// supportedJWK tells whether a key of the given type and curve can verify tokens
+func supportedJWK(kty, crv string) bool {
+	switch kty {
+	case "RSA", "oct":
+		return true
+	case "EC":
+		return crv == "P-256"
+	}
+	return false
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,151 @@
+package rest
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const codeParamIsInvalid = "param_is_invalid"
+
+// defaultCodes maps the validation tags to the error codes
+var defaultCodes = map[string]string{
+	"required": "param_is_required",
+	"len":      "param_invalid_length",
+	"min":      "param_length_below_minimum",
+	"max":      "param_length_over_maximum",
+	"oneof":    "param_is_not_present_in_enum",
+	"email":    "param_is_not_an_email",
+	"unique":   "param_repeated_values",
+
+	"eqfield":          "param_must_be_equal_to_field",
+	"nefield":          "param_must_not_be_equal_to_field",
+	"gtfield":          "param_must_be_greater_than_field",
+	"gtefield":         "param_must_be_greater_or_equal_to_field",
+	"ltfield":          "param_must_be_lower_than_field",
+	"ltefield":         "param_must_be_lower_or_equal_to_field",
+	"required_with":    "param_is_required_with_field",
+	"required_without": "param_is_required_without_field",
+}
+
+// crossFieldTags have the name of another field as parameter
+var crossFieldTags = map[string]bool{
+	"eqfield":          true,
+	"nefield":          true,
+	"gtfield":          true,
+	"gtefield":         true,
+	"ltfield":          true,
+	"ltefield":         true,
+	"required_with":    true,
+	"required_without": true,
+}
+
+// defaultMessages of every error code by locale. {0} is the path of the field and {1} the parameter of the rule
+var defaultMessages = map[string]map[string]string{
+	"en": {
+		"param_is_required":            "'{0}' is required",
+		"param_invalid_length":         "'{0}' must have a length equal to {1}",
+		"param_length_below_minimum":   "'{0}' has a minimum length of {1}",
+		"param_length_over_maximum":    "'{0}' has a maximum length of {1}",
+		"param_is_not_present_in_enum": "'{0}' must be one of: {1}",
+		"param_is_not_an_email":        "'{0}' must be a valid email address",
+		"param_repeated_values":        "'{0}' does not allow repeated values",
+		codeParamIsInvalid:             "'{0}' is invalid, must meet the requirements of the tag {1}",
+
+		"param_must_be_equal_to_field":            "'{0}' must be equal to '{1}'",
+		"param_must_not_be_equal_to_field":        "'{0}' must not be equal to '{1}'",
+		"param_must_be_greater_than_field":        "'{0}' must be greater than '{1}'",
+		"param_must_be_greater_or_equal_to_field": "'{0}' must be greater than or equal to '{1}'",
+		"param_must_be_lower_than_field":          "'{0}' must be lower than '{1}'",
+		"param_must_be_lower_or_equal_to_field":   "'{0}' must be lower than or equal to '{1}'",
+		"param_is_required_with_field":            "'{0}' is required when '{1}' is present",
+		"param_is_required_without_field":         "'{0}' is required when '{1}' is not present",
+	},
+	"es": {
+		"param_is_required":            "'{0}' es obligatorio",
+		"param_invalid_length":         "'{0}' debe tener una longitud igual a {1}",
+		"param_length_below_minimum":   "'{0}' tiene una longitud mínima de {1}",
+		"param_length_over_maximum":    "'{0}' tiene una longitud máxima de {1}",
+		"param_is_not_present_in_enum": "'{0}' debe ser uno de: {1}",
+		"param_is_not_an_email":        "'{0}' debe ser una dirección de correo electrónico válida",
+		"param_repeated_values":        "'{0}' no permite valores repetidos",
+		codeParamIsInvalid:             "'{0}' no es válido, debe cumplir los requisitos de la etiqueta {1}",
+
+		"param_must_be_equal_to_field":            "'{0}' debe ser igual a '{1}'",
+		"param_must_not_be_equal_to_field":        "'{0}' no debe ser igual a '{1}'",
+		"param_must_be_greater_than_field":        "'{0}' debe ser mayor que '{1}'",
+		"param_must_be_greater_or_equal_to_field": "'{0}' debe ser mayor o igual que '{1}'",
+		"param_must_be_lower_than_field":          "'{0}' debe ser menor que '{1}'",
+		"param_must_be_lower_or_equal_to_field":   "'{0}' debe ser menor o igual que '{1}'",
+		"param_is_required_with_field":            "'{0}' es obligatorio cuando '{1}' está presente",
+		"param_is_required_without_field":         "'{0}' es obligatorio cuando '{1}' no está presente",
+	},
+	"pt": {
+		"param_is_required":            "'{0}' é obrigatório",
+		"param_invalid_length":         "'{0}' deve ter um comprimento igual a {1}",
+		"param_length_below_minimum":   "'{0}' tem um comprimento mínimo de {1}",
+		"param_length_over_maximum":    "'{0}' tem um comprimento máximo de {1}",
+		"param_is_not_present_in_enum": "'{0}' deve ser um de: {1}",
+		"param_is_not_an_email":        "'{0}' deve ser um endereço de e-mail válido",
+		"param_repeated_values":        "'{0}' não permite valores repetidos",
+		codeParamIsInvalid:             "'{0}' é inválido, deve atender aos requisitos da tag {1}",
+
+		"param_must_be_equal_to_field":            "'{0}' deve ser igual a '{1}'",
+		"param_must_not_be_equal_to_field":        "'{0}' não deve ser igual a '{1}'",
+		"param_must_be_greater_than_field":        "'{0}' deve ser maior que '{1}'",
+		"param_must_be_greater_or_equal_to_field": "'{0}' deve ser maior ou igual a '{1}'",
+		"param_must_be_lower_than_field":          "'{0}' deve ser menor que '{1}'",
+		"param_must_be_lower_or_equal_to_field":   "'{0}' deve ser menor ou igual a '{1}'",
+		"param_is_required_with_field":            "'{0}' é obrigatório quando '{1}' está presente",
+		"param_is_required_without_field":         "'{0}' é obrigatório quando '{1}' não está presente",
+	},
+}
+
+// Languages of the request in order of preference. The Language header goes first,
+// followed by the languages of the Accept-Language header sorted by quality.
+// Regional languages are followed by their base language, i.e. "es-AR" returns "es_AR" and "es"
+func Languages(r *Request) []string {
+	if r.Request == nil {
+		return nil
+	}
+
+	type language struct {
+		tag string
+		q   float64
+	}
+
+	var langs []language
+	if lang := strings.TrimSpace(r.Header.Get(LanguageHeader)); lang != "" {
+		langs = append(langs, language{tag: lang, q: 2})
+	}
+
+	for _, part := range strings.Split(r.Header.Get(AcceptLanguageHeader), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil || parsed <= 0 {
+				continue
+			}
+			q = parsed
+		}
+		langs = append(langs, language{tag: tag, q: q})
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+
+	var res []string
+	for _, l := range langs {
+		tag := strings.ReplaceAll(strings.TrimSpace(l.tag), "-", "_")
+		res = append(res, tag)
+		if base, _, regional := strings.Cut(tag, "_"); regional {
+			res = append(res, base)
+		}
+	}
+	return res
+}
@@ -0,0 +1,50 @@
+package rest
+
+// Option configures UpgradeMiddleware and the routers built on top of it
+type Option func(o *options)
+
+type options struct {
+	encoders       *Encoders
+	problemDetails bool
+	problemTypeURI string
+	panicHook      PanicHook
+	requestID      *RequestIDConfig
+	accessLog      *AccessLogConfig
+	ipResolver     *IPResolver
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		encoders: DefaultEncoders(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithEncoders sets the encoders used to write the data of the responses
+func WithEncoders(encoders *Encoders) Option {
+	return func(o *options) {
+		o.encoders = encoders
+	}
+}
+
+// WithProblemDetails writes the error responses as RFC 7807 application/problem+json documents.
+// When typeURI is not empty the problem type is typeURI followed by the error code, otherwise it is "about:blank"
+func WithProblemDetails(typeURI string) Option {
+	return func(o *options) {
+		o.problemDetails = true
+		o.problemTypeURI = typeURI
+	}
+}
+
+// PanicHook receives the value and the stack trace of a panic recovered while serving a request
+type PanicHook func(r *Request, recovered interface{}, stack []byte)
+
+// WithPanicHook calls the hook after a panic is recovered, i.e. to send it to an error reporter
+func WithPanicHook(hook PanicHook) Option {
+	return func(o *options) {
+		o.panicHook = hook
+	}
+}
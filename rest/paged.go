@@ -1,17 +1,104 @@
 package rest
 
 import (
+	"sort"
 	"strconv"
+	"strings"
 )
 
 // Filter to get paged results
 type Filter struct {
-	Limit    int64  `json:"limit"`
-	Offset   int64  `json:"offset"`
-	FromID   string `json:"fromId"`
-	ToID     string `json:"toId"`
-	FromDate int64  `json:"fromDate"`
-	ToDate   int64  `json:"toDate"`
+	Limit      int64       `json:"limit"`
+	Offset     int64       `json:"offset"`
+	FromID     string      `json:"fromId"`
+	ToID       string      `json:"toId"`
+	FromDate   int64       `json:"fromDate"`
+	ToDate     int64       `json:"toDate"`
+	Cursor     *Cursor     `json:"-"`
+	Sort       []SortField `json:"sort"`
+	Conditions []Condition `json:"conditions"`
+}
+
+// SortField of a paged result
+type SortField struct {
+	Field      string `json:"field"`
+	Descending bool   `json:"descending"`
+}
+
+// Operator of a filter condition
+type Operator string
+
+const (
+	// OpEq equal to
+	OpEq Operator = "eq"
+	// OpNe not equal to
+	OpNe Operator = "ne"
+	// OpGt greater than
+	OpGt Operator = "gt"
+	// OpGte greater than or equal to
+	OpGte Operator = "gte"
+	// OpLt lower than
+	OpLt Operator = "lt"
+	// OpLte lower than or equal to
+	OpLte Operator = "lte"
+	// OpIn one of a list of values
+	OpIn Operator = "in"
+	// OpNin none of a list of values
+	OpNin Operator = "nin"
+)
+
+var operators = map[Operator]bool{OpEq: true, OpNe: true, OpGt: true, OpGte: true, OpLt: true, OpLte: true, OpIn: true, OpNin: true}
+
+// FilterKind is the type of the values of a filterable field
+type FilterKind int
+
+const (
+	// FilterString values are kept as strings
+	FilterString FilterKind = iota
+	// FilterInteger values are parsed as int64
+	FilterInteger
+	// FilterNumber values are parsed as float64
+	FilterNumber
+	// FilterBool values are parsed as bool
+	FilterBool
+)
+
+// FilterField describes a query param that can be used as a filter
+type FilterField struct {
+	Kind FilterKind
+	// Operators allowed for the field. Every operator is allowed when empty
+	Operators []Operator
+}
+
+func (f FilterField) allows(op Operator) bool {
+	if len(f.Operators) == 0 {
+		return true
+	}
+	for _, o := range f.Operators {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Condition of a filter. The type of the values depends on the FilterKind of the field
+type Condition struct {
+	Field    string        `json:"field"`
+	Operator Operator      `json:"operator"`
+	Values   []interface{} `json:"values"`
+}
+
+// PagingConfig of a paged endpoint
+type PagingConfig struct {
+	// DefaultLimit is used when the limit is not present
+	DefaultLimit int64
+	// MaxLimit caps the limit requested by the client. There is no cap when it is 0
+	MaxLimit int64
+	// Sortable fields that can be used in the sort param, i.e. sort=-createdAt,name
+	Sortable []string
+	// Filterable query params, i.e. status=in:active,blocked or amount=gte:100
+	Filterable map[string]FilterField
 }
 
 // Paging params
@@ -29,55 +116,164 @@ type Page struct {
 	Result interface{} `json:"result"`
 }
 
-// PagedEndpointMiddleware adds filters to get a paged result
+// PagedEndpointMiddleware adds filters to get a paged result. The sort param is ignored,
+// use PagedMiddleware to sort by the allowed fields
 func PagedEndpointMiddleware(handler HandlerFunc) HandlerFunc {
-	return func(r *Request) *Response {
-		limitStr := r.QueryParam("limit")
-		if limitStr != "" {
-			limit, err := strconv.ParseInt(limitStr, 10, 64)
-			if err != nil {
-				return BadRequest(ErrInvalidNumberParam("limit"))
+	return pagedMiddleware(PagingConfig{}, true)(handler)
+}
+
+// PagedMiddleware adds filters to get a paged result with sorting and filtering
+// restricted to the fields of the config
+func PagedMiddleware(config PagingConfig) Middleware {
+	return pagedMiddleware(config, false)
+}
+
+// pagedMiddleware ignores the sort param when ignoreSort is true
+func pagedMiddleware(config PagingConfig, ignoreSort bool) Middleware {
+	sortable := map[string]bool{}
+	for _, field := range config.Sortable {
+		sortable[field] = true
+	}
+
+	filterable := make([]string, 0, len(config.Filterable))
+	for field := range config.Filterable {
+		filterable = append(filterable, field)
+	}
+	sort.Strings(filterable)
+
+	return func(handler HandlerFunc) HandlerFunc {
+		return func(r *Request) *Response {
+			if r.Filter == nil {
+				r.Filter = &Filter{}
+			}
+			if res := parsePaging(r, config); res != nil {
+				return res
+			}
+
+			sortStr := r.QueryParam("sort")
+			if sortStr != "" && !ignoreSort {
+				for _, field := range strings.Split(sortStr, ",") {
+					field = strings.TrimSpace(field)
+					descending := strings.HasPrefix(field, "-")
+					field = strings.TrimLeft(field, "+-")
+					if !sortable[field] {
+						return BadRequest(ErrInvalidSortParam(field))
+					}
+					r.Filter.Sort = append(r.Filter.Sort, SortField{Field: field, Descending: descending})
+				}
 			}
-			r.Filter.Limit = limit
+
+			for _, field := range filterable {
+				for _, value := range r.QueryParams(field) {
+					condition, err := parseCondition(field, value, config.Filterable[field])
+					if err != nil {
+						return BadRequest(err)
+					}
+					r.Filter.Conditions = append(r.Filter.Conditions, condition)
+				}
+			}
+
+			return handler(r)
 		}
+	}
+}
+
+func parseCondition(field, value string, f FilterField) (Condition, error) {
+	op := OpEq
+	if prefix, rest, found := strings.Cut(value, ":"); found && operators[Operator(prefix)] {
+		op, value = Operator(prefix), rest
+	}
+	if !f.allows(op) {
+		return Condition{}, ErrInvalidFilterParam(field)
+	}
 
-		offsetStr := r.QueryParam("offset")
-		if offsetStr != "" {
-			offset, err := strconv.ParseInt(offsetStr, 10, 64)
+	raw := []string{value}
+	if op == OpIn || op == OpNin {
+		raw = strings.Split(value, ",")
+	}
+
+	values := make([]interface{}, 0, len(raw))
+	for _, v := range raw {
+		switch f.Kind {
+		case FilterInteger:
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return Condition{}, ErrInvalidNumberParam(field)
+			}
+			values = append(values, n)
+		case FilterNumber:
+			n, err := strconv.ParseFloat(v, 64)
 			if err != nil {
-				return BadRequest(ErrInvalidNumberParam("offset"))
+				return Condition{}, ErrInvalidNumberParam(field)
 			}
-			r.Filter.Offset = offset
+			values = append(values, n)
+		case FilterBool:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return Condition{}, ErrInvalidBoolParam(field)
+			}
+			values = append(values, b)
+		default:
+			if v == "" {
+				return Condition{}, ErrInvalidStringParam(field)
+			}
+			values = append(values, v)
 		}
+	}
 
-		fromID := r.QueryParam("fromId")
-		if fromID != "" {
-			r.Filter.FromID = fromID
-		}
+	return Condition{Field: field, Operator: op, Values: values}, nil
+}
 
-		toID := r.QueryParam("toId")
-		if len(toID) > 0 {
-			r.Filter.ToID = toID
+func parsePaging(r *Request, config PagingConfig) *Response {
+	r.Filter.Limit = config.DefaultLimit
+	limitStr := r.QueryParam("limit")
+	if limitStr != "" {
+		limit, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || limit < 0 {
+			return BadRequest(ErrInvalidNumberParam("limit"))
 		}
+		r.Filter.Limit = limit
+	}
+	if config.MaxLimit > 0 && (r.Filter.Limit == 0 || r.Filter.Limit > config.MaxLimit) {
+		r.Filter.Limit = config.MaxLimit
+	}
 
-		strFromDate := r.QueryParam("fromDate")
-		if strFromDate != "" {
-			fromDate, err := strconv.ParseInt(strFromDate, 10, 64)
-			if err != nil {
-				return BadRequest(ErrInvalidNumberParam("fromDate"))
-			}
-			r.Filter.FromDate = fromDate
+	offsetStr := r.QueryParam("offset")
+	if offsetStr != "" {
+		offset, err := strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil || offset < 0 {
+			return BadRequest(ErrInvalidNumberParam("offset"))
 		}
+		r.Filter.Offset = offset
+	}
 
-		strToDate := r.QueryParam("toDate")
-		if strToDate != "" {
-			toDate, err := strconv.ParseInt(strToDate, 10, 64)
-			if err != nil {
-				return BadRequest(ErrInvalidNumberParam("toDate"))
-			}
-			r.Filter.ToDate = toDate
+	fromID := r.QueryParam("fromId")
+	if fromID != "" {
+		r.Filter.FromID = fromID
+	}
+
+	toID := r.QueryParam("toId")
+	if len(toID) > 0 {
+		r.Filter.ToID = toID
+	}
+
+	strFromDate := r.QueryParam("fromDate")
+	if strFromDate != "" {
+		fromDate, err := strconv.ParseInt(strFromDate, 10, 64)
+		if err != nil {
+			return BadRequest(ErrInvalidNumberParam("fromDate"))
 		}
+		r.Filter.FromDate = fromDate
+	}
 
-		return handler(r)
+	strToDate := r.QueryParam("toDate")
+	if strToDate != "" {
+		toDate, err := strconv.ParseInt(strToDate, 10, 64)
+		if err != nil {
+			return BadRequest(ErrInvalidNumberParam("toDate"))
+		}
+		r.Filter.ToDate = toDate
 	}
+
+	return nil
 }
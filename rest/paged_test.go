@@ -0,0 +1,95 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagedMiddleware(main *testing.T) {
+	config := rest.PagingConfig{
+		DefaultLimit: 20,
+		MaxLimit:     100,
+		Sortable:     []string{"createdAt", "name"},
+		Filterable: map[string]rest.FilterField{
+			"status": {Kind: rest.FilterString, Operators: []rest.Operator{rest.OpEq, rest.OpIn}},
+			"amount": {Kind: rest.FilterNumber},
+		},
+	}
+
+	run := func(target string) (*rest.Filter, *rest.Response) {
+		var filter *rest.Filter
+		handler := rest.PagedMiddleware(config)(func(r *rest.Request) *rest.Response {
+			filter = r.Filter
+			return rest.NoContent()
+		})
+		res := handler(&rest.Request{Request: httptest.NewRequest(http.MethodGet, target, nil), Filter: &rest.Filter{}})
+		return filter, res
+	}
+
+	main.Run("The default limit is used and the maximum is enforced", func(t *testing.T) {
+		filter, _ := run("/orders")
+		require.Equal(t, int64(20), filter.Limit)
+
+		filter, _ = run("/orders?limit=500")
+		require.Equal(t, int64(100), filter.Limit)
+	})
+
+	main.Run("Sort fields are parsed in order", func(t *testing.T) {
+		filter, _ := run("/orders?sort=-createdAt,name")
+		require.Equal(t, []rest.SortField{{Field: "createdAt", Descending: true}, {Field: "name"}}, filter.Sort)
+	})
+
+	main.Run("Filters are parsed into typed conditions", func(t *testing.T) {
+		filter, _ := run("/orders?status=in:active,blocked&amount=gte:100&amount=lt:200.5&other=x")
+		require.Equal(t, []rest.Condition{
+			{Field: "amount", Operator: rest.OpGte, Values: []interface{}{float64(100)}},
+			{Field: "amount", Operator: rest.OpLt, Values: []interface{}{200.5}},
+			{Field: "status", Operator: rest.OpIn, Values: []interface{}{"active", "blocked"}},
+		}, filter.Conditions)
+
+		filter, _ = run("/orders?status=active")
+		require.Equal(t, []rest.Condition{{Field: "status", Operator: rest.OpEq, Values: []interface{}{"active"}}}, filter.Conditions)
+	})
+
+	main.Run("Disallowed fields and operators are rejected", func(t *testing.T) {
+		_, res := run("/orders?sort=password")
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "'password' is not a sortable field", res.Err)
+
+		_, res = run("/orders?status=gt:active")
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "invalid_param_value", res.Code)
+
+		_, res = run("/orders?amount=gte:a-lot")
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "'amount' must be a valid number", res.Err)
+
+		_, res = run("/orders?limit=-1")
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		_, res = run("/orders?offset=-1")
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "'offset' must be a valid number", res.Err)
+	})
+}
+
+func TestPagedEndpointMiddleware(t *testing.T) {
+	var filter *rest.Filter
+	handler := rest.PagedEndpointMiddleware(func(r *rest.Request) *rest.Response {
+		filter = r.Filter
+		return rest.NoContent()
+	})
+
+	res := handler(&rest.Request{Request: httptest.NewRequest(http.MethodGet, "/orders?sort=-anything,,name&limit=10&offset=20", nil), Filter: &rest.Filter{}})
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+	require.Equal(t, int64(10), filter.Limit)
+	require.Equal(t, int64(20), filter.Offset)
+	require.Empty(t, filter.Sort)
+
+	res = handler(&rest.Request{Request: httptest.NewRequest(http.MethodGet, "/orders?offset=-1", nil), Filter: &rest.Filter{}})
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	require.Equal(t, "invalid_param_type", res.Code)
+}
@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Problem details of an error response as defined by RFC 7807.
+// Code and Errors are extension members
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem builds the problem details of an error response. The tracking id is used as instance.
+// The message of errors without a code is only used as detail of client errors, since the
+// ones of server errors can have internal details
+func NewProblem(res *Response, trackingID, typeURI string) *Problem {
+	p := &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(res.StatusCode),
+		Status:   res.StatusCode,
+		Detail:   res.Err,
+		Instance: trackingID,
+		Code:     res.Code,
+		Errors:   res.Errors,
+	}
+	if p.Detail == "" && res.err != nil && res.StatusCode < http.StatusInternalServerError {
+		p.Detail = res.err.Error()
+	}
+	if typeURI != "" && res.Code != "" {
+		p.Type = strings.TrimSuffix(typeURI, "/") + "/" + res.Code
+	}
+	return p
+}
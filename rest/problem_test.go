@@ -0,0 +1,76 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gonzispina/gokit/errors"
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemDetails(main *testing.T) {
+	errOrderNotFound := errors.New("order not found", "order_not_found")
+
+	router := rest.NewRouter(logs.InitTest(), rest.WithProblemDetails("https://errors.example.com/"))
+	router.Get("/orders/{orderId}", func(r *rest.Request) *rest.Response {
+		return rest.NotFound(errOrderNotFound)
+	})
+	router.Get("/broken", func(r *rest.Request) *rest.Response {
+		return rest.NewError(http.StatusBadGateway, fmt.Errorf("dial tcp 10.0.0.1:27017: i/o timeout"))
+	})
+	router.Delete("/orders/{orderId}", func(r *rest.Request) *rest.Response {
+		return rest.NewError(http.StatusConflict, fmt.Errorf("the order is already paid"))
+	})
+	router.Post("/orders", rest.JSON(rest.NewValidator(), func(r *rest.Request, body *order) *rest.Response {
+		return rest.Created(body)
+	}))
+
+	do := func(req *http.Request) (*httptest.ResponseRecorder, rest.Problem) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var p rest.Problem
+		require.NoError(main, json.NewDecoder(w.Body).Decode(&p))
+		return w, p
+	}
+
+	main.Run("Errors are written as problem+json", func(t *testing.T) {
+		w, p := do(httptest.NewRequest(http.MethodGet, "/orders/1", nil))
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Equal(t, "application/problem+json", w.Header().Get(rest.ContentTypeHeader))
+		require.Equal(t, "https://errors.example.com/order_not_found", p.Type)
+		require.Equal(t, "Not Found", p.Title)
+		require.Equal(t, http.StatusNotFound, p.Status)
+		require.Equal(t, "order not found", p.Detail)
+		require.Equal(t, "order_not_found", p.Code)
+		require.NotEmpty(t, p.Instance)
+	})
+
+	main.Run("The message of standard errors is kept for client errors", func(t *testing.T) {
+		_, p := do(httptest.NewRequest(http.MethodDelete, "/orders/1", nil))
+		require.Equal(t, "about:blank", p.Type)
+		require.Equal(t, "the order is already paid", p.Detail)
+
+		w, p := do(httptest.NewRequest(http.MethodGet, "/broken", nil))
+		require.Equal(t, http.StatusBadGateway, w.Code)
+		require.Empty(t, p.Detail)
+	})
+
+	main.Run("Validation problems are listed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"quantity":1}`))
+		req.Header.Set(rest.ContentTypeHeader, "application/json")
+
+		w, p := do(req)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Equal(t, "param_is_required", p.Code)
+		require.Len(t, p.Errors, 1)
+		require.Equal(t, "product", p.Errors[0].Field)
+		require.Equal(t, "required", p.Errors[0].Rule)
+	})
+}
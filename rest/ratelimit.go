@@ -0,0 +1,371 @@
+package rest
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gonzispina/gokit/context"
+	"github.com/gonzispina/gokit/errors"
+	"github.com/gonzispina/gokit/logs"
+)
+
+var (
+	// ErrRateLimited when the caller made too many requests
+	ErrRateLimited = errors.New("too many requests, try again later", "rate_limit_exceeded")
+	// ErrRateLimitUnavailable when the store of the rate limit fails
+	ErrRateLimitUnavailable = errors.New("the rate limit cannot be checked, try again later", "rate_limit_unavailable")
+
+	errRateLimitContention = errors.New("the rate limit state changed too many times", "rate_limit_contention")
+)
+
+// maxRateLimitSwaps is how many times the state is read again when another request changed it
+const maxRateLimitSwaps = 16
+
+// Algorithm of a rate limit
+type Algorithm int
+
+const (
+	// TokenBucket allows bursts of up to Burst requests and refills Limit requests every Period
+	TokenBucket Algorithm = iota
+	// SlidingWindow allows Limit requests in any Period. The count is estimated from the
+	// counts of the current and the previous windows
+	SlidingWindow
+)
+
+// RateLimitKey returns the key the requests are counted by. Requests with an empty key are not limited
+type RateLimitKey func(r *Request) string
+
+// KeyByUserID counts the requests of each authenticated user, see Request.Authenticated.
+// The rest of the requests are counted by IP address, since their UserID can be anything
+func KeyByUserID(r *Request) string {
+	if r.Authenticated() && r.UserID != "" {
+		return "user:" + r.UserID
+	}
+	return KeyByIP(r)
+}
+
+// KeyByIP counts the requests of each IP address. Use WithIPResolver so the address of the
+// client is used instead of the one of the proxy
+func KeyByIP(r *Request) string {
+	if r.IPAddress == "" {
+		return ""
+	}
+	return "ip:" + r.IPAddress
+}
+
+// KeyByRoute counts the requests of each route, no matter who makes them
+func KeyByRoute(r *Request) string {
+	if r.Request == nil {
+		return ""
+	}
+	rctx := chi.RouteContext(r.Request.Context())
+	if rctx == nil || rctx.RoutePattern() == "" {
+		return ""
+	}
+	return "route:" + r.Method + " " + rctx.RoutePattern()
+}
+
+// KeyBy combines the keys, e.g. KeyBy(KeyByUserID, KeyByRoute) counts the requests of each user to each route
+func KeyBy(keys ...RateLimitKey) RateLimitKey {
+	return func(r *Request) string {
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			part := key(r)
+			if part == "" {
+				return ""
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+// RateLimitState of a key. TokenBucket uses Tokens and SlidingWindow uses Count and Previous
+type RateLimitState struct {
+	// Tokens left in the bucket
+	Tokens float64 `bson:"tokens"`
+	// Count of requests in the current window
+	Count int64 `bson:"count"`
+	// Previous count of requests in the window before the current one
+	Previous int64 `bson:"previous"`
+	// Timestamp of the last refill of the bucket or the start of the current window
+	Timestamp time.Time `bson:"timestamp"`
+	// ExpiresAt is when the state can be discarded because the limit is fully available again
+	ExpiresAt time.Time `bson:"expiresAt"`
+	// Version is increased on every change to detect concurrent updates
+	Version int64 `bson:"version"`
+}
+
+// RateLimitStore keeps the state of the rate limits. The algorithms read the state and save the
+// new one only if nobody changed it in the meantime, so the stores only need a compare and swap
+type RateLimitStore interface {
+	// Get the state of the key. It returns false if the key doesn't exist
+	Get(ctx context.Context, key string) (RateLimitState, bool, error)
+	// Swap saves the state if the version of the key is still old, 0 for a key that doesn't exist.
+	// It returns false if the key was changed
+	Swap(ctx context.Context, key string, old int64, state RateLimitState) (bool, error)
+}
+
+// RateLimitConfig of a rate limit
+type RateLimitConfig struct {
+	// Name of the limit, prepended to the keys so several limits can share a store
+	Name string
+	// Algorithm of the limit, TokenBucket by default
+	Algorithm Algorithm
+	// Limit of requests allowed in a Period
+	Limit int
+	// Period of the limit
+	Period time.Duration
+	// Burst is the size of the bucket of TokenBucket, Limit by default
+	Burst int
+	// Key the requests are counted by, KeyByIP by default
+	Key RateLimitKey
+	// Store of the state, in memory by default
+	Store RateLimitStore
+	// FailOpen allows the requests when the store fails. By default they get 503 Service Unavailable
+	FailOpen bool
+}
+
+// RateLimitResult of a request
+type RateLimitResult struct {
+	// Allowed tells whether the request can be made
+	Allowed bool
+	// Limit of requests
+	Limit int
+	// Remaining requests that can be made right now
+	Remaining int
+	// Reset is the time until the limit is fully available again
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, when this one is not
+	RetryAfter time.Duration
+}
+
+// RateLimiter counts the requests of each key
+type RateLimiter struct {
+	config RateLimitConfig
+}
+
+// NewRateLimiter returns a limiter with the config. It panics if the limit or the period are not set
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	if config.Limit <= 0 || config.Period <= 0 {
+		panic("rate limit and period must be greater than 0")
+	}
+	if config.Burst <= 0 {
+		config.Burst = config.Limit
+	}
+	if config.Key == nil {
+		config.Key = KeyByIP
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryRateLimitStore()
+	}
+	return &RateLimiter{config: config}
+}
+
+// Allow counts a request of the key and tells whether it is allowed
+func (l *RateLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	if l.config.Name != "" {
+		key = l.config.Name + ":" + key
+	}
+
+	for i := 0; i < maxRateLimitSwaps; i++ {
+		state, found, err := l.config.Store.Get(ctx, key)
+		if err != nil {
+			return RateLimitResult{}, err
+		}
+		if !found {
+			state = RateLimitState{}
+		}
+
+		next, res := l.take(state, found, time.Now())
+		if !res.Allowed {
+			return res, nil
+		}
+
+		next.Version = state.Version + 1
+		swapped, err := l.config.Store.Swap(ctx, key, state.Version, next)
+		if err != nil {
+			return RateLimitResult{}, err
+		}
+		if swapped {
+			return res, nil
+		}
+	}
+	return RateLimitResult{}, errRateLimitContention
+}
+
+func (l *RateLimiter) take(state RateLimitState, found bool, now time.Time) (RateLimitState, RateLimitResult) {
+	if l.config.Algorithm == SlidingWindow {
+		return l.slidingWindow(state, found, now)
+	}
+	return l.tokenBucket(state, found, now)
+}
+
+func (l *RateLimiter) tokenBucket(state RateLimitState, found bool, now time.Time) (RateLimitState, RateLimitResult) {
+	capacity := float64(l.config.Burst)
+	perSecond := float64(l.config.Limit) / l.config.Period.Seconds()
+
+	tokens := capacity
+	if found {
+		elapsed := now.Sub(state.Timestamp).Seconds()
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		tokens = math.Min(capacity, state.Tokens+elapsed*perSecond)
+	}
+
+	res := RateLimitResult{Limit: l.config.Burst}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / perSecond)
+	}
+	res.Remaining = int(tokens)
+	res.Reset = seconds((capacity - tokens) / perSecond)
+
+	return RateLimitState{Tokens: tokens, Timestamp: now, ExpiresAt: now.Add(res.Reset)}, res
+}
+
+func (l *RateLimiter) slidingWindow(state RateLimitState, found bool, now time.Time) (RateLimitState, RateLimitResult) {
+	period := l.config.Period
+	start := now.Truncate(period)
+
+	var count, previous int64
+	if found {
+		switch {
+		case state.Timestamp.Equal(start):
+			count, previous = state.Count, state.Previous
+		case state.Timestamp.Equal(start.Add(-period)):
+			previous = state.Count
+		}
+	}
+
+	limit := float64(l.config.Limit)
+	weight := 1 - float64(now.Sub(start))/float64(period)
+	estimated := float64(previous)*weight + float64(count)
+
+	res := RateLimitResult{Limit: l.config.Limit}
+	if estimated+1 <= limit {
+		count++
+		estimated++
+		res.Allowed = true
+	} else if room := limit - 1 - float64(count); room >= 0 && previous > 0 {
+		// the request fits once enough of the previous window slides out
+		res.RetryAfter = start.Add(time.Duration(float64(period) * (1 - room/float64(previous)))).Sub(now)
+	} else {
+		// the request fits once enough of the current window slides out
+		res.RetryAfter = start.Add(period + time.Duration(float64(period)*(1-(limit-1)/float64(count)))).Sub(now)
+	}
+	res.Remaining = int(math.Max(0, limit-math.Ceil(estimated)))
+
+	expiresAt := start.Add(2 * period)
+	if count == 0 {
+		expiresAt = start.Add(period)
+	}
+	res.Reset = expiresAt.Sub(now)
+
+	return RateLimitState{Count: count, Previous: previous, Timestamp: start, ExpiresAt: expiresAt}, res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// RateLimitMiddleware limits the requests of each key. The responses have the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers and the requests over the limit get 429 Too Many
+// Requests with a Retry-After header. If the store fails the error is logged and the request gets
+// 503 Service Unavailable, or it is allowed when the limit is FailOpen
+func RateLimitMiddleware(logger logs.Logger, config RateLimitConfig) Middleware {
+	limiter := NewRateLimiter(config)
+
+	return func(handler HandlerFunc) HandlerFunc {
+		return func(r *Request) *Response {
+			key := limiter.config.Key(r)
+			if key == "" {
+				return handler(r)
+			}
+
+			res, err := limiter.Allow(r.Context(), key)
+			if err != nil {
+				logger.Error(r.Context(), "Couldn't check the rate limit", logs.Error(err))
+				if limiter.config.FailOpen {
+					return handler(r)
+				}
+				return ServiceUnavailable(ErrRateLimitUnavailable)
+			}
+
+			if !res.Allowed {
+				response := TooManyRequests(ErrRateLimited)
+				res.setHeaders(response)
+				response.Header[RetryAfterHeader] = strconv.Itoa(ceilSeconds(res.RetryAfter))
+				return response
+			}
+
+			response := handler(r)
+			if response != nil {
+				if response.Header == nil {
+					response.Header = map[string]string{}
+				}
+				res.setHeaders(response)
+			}
+			return response
+		}
+	}
+}
+
+func (res RateLimitResult) setHeaders(response *Response) {
+	response.Header[RateLimitLimitHeader] = strconv.Itoa(res.Limit)
+	response.Header[RateLimitRemainingHeader] = strconv.Itoa(res.Remaining)
+	response.Header[RateLimitResetHeader] = strconv.Itoa(ceilSeconds(res.Reset))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// MemoryRateLimitStore keeps the state in memory, for single instance services
+type MemoryRateLimitStore struct {
+	mu     sync.Mutex
+	states map[string]RateLimitState
+	writes int
+}
+
+// NewMemoryRateLimitStore returns an empty store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{states: map[string]RateLimitState{}}
+}
+
+// Get the state of the key
+func (s *MemoryRateLimitStore) Get(ctx context.Context, key string) (RateLimitState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[key]
+	return state, ok, nil
+}
+
+// Swap saves the state if the key wasn't changed
+func (s *MemoryRateLimitStore) Swap(ctx context.Context, key string, old int64, state RateLimitState) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states[key].Version != old {
+		return false, nil
+	}
+	s.states[key] = state
+
+	// the expired states are removed from time to time so the map doesn't grow forever
+	s.writes++
+	if s.writes%1024 == 0 {
+		now := time.Now()
+		for k, v := range s.states {
+			if v.ExpiresAt.Before(now) {
+				delete(s.states, k)
+			}
+		}
+	}
+	return true, nil
+}
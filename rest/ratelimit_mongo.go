@@ -0,0 +1,71 @@
+package rest
+
+import (
+	"github.com/gonzispina/gokit/context"
+	"github.com/gonzispina/gokit/errors"
+	"github.com/gonzispina/gokit/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+	mongooptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type rateLimitDocument struct {
+	Key            string `bson:"_id"`
+	RateLimitState `bson:",inline"`
+}
+
+// MongoRateLimitStore keeps the state in a mongo collection, so the limits are shared by every instance
+type MongoRateLimitStore struct {
+	mongo      *mongo.Mongo
+	collection string
+}
+
+// NewMongoRateLimitStore for the collection
+func NewMongoRateLimitStore(m *mongo.Mongo, collection string) *MongoRateLimitStore {
+	return &MongoRateLimitStore{mongo: m, collection: collection}
+}
+
+// Collection definition with the indexes of the store, to be created with mongo.CreateIndexes.
+// The expired states are removed by a TTL index
+func (s *MongoRateLimitStore) Collection() mongo.Collection {
+	return mongo.Collection{
+		Name: s.collection,
+		Indexes: []mongo.IndexModel{
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: mongooptions.Index().SetExpireAfterSeconds(0)},
+		},
+	}
+}
+
+// Get the state of the key
+func (s *MongoRateLimitStore) Get(ctx context.Context, key string) (RateLimitState, bool, error) {
+	var doc rateLimitDocument
+	err := s.mongo.Collection(s.collection).FindOne(ctx, bson.M{"_id": key}).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return RateLimitState{}, false, nil
+		}
+		return RateLimitState{}, false, err
+	}
+	return doc.RateLimitState, true, nil
+}
+
+// Swap saves the state if the key wasn't changed
+func (s *MongoRateLimitStore) Swap(ctx context.Context, key string, old int64, state RateLimitState) (bool, error) {
+	collection := s.mongo.Collection(s.collection)
+	if old == 0 {
+		_, err := collection.InsertOne(ctx, rateLimitDocument{Key: key, RateLimitState: state})
+		if err != nil {
+			if mongodriver.IsDuplicateKeyError(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	res, err := collection.UpdateOne(ctx, bson.M{"_id": key, "version": old}, bson.M{"$set": state})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount == 1, nil
+}
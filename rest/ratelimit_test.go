@@ -0,0 +1,150 @@
+package rest_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gonzispina/gokit/context"
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore fails every operation
+type failingStore struct{}
+
+func (failingStore) Get(ctx context.Context, key string) (rest.RateLimitState, bool, error) {
+	return rest.RateLimitState{}, false, fmt.Errorf("store is down")
+}
+
+func (failingStore) Swap(ctx context.Context, key string, old int64, state rest.RateLimitState) (bool, error) {
+	return false, fmt.Errorf("store is down")
+}
+
+func TestRateLimitMiddleware(main *testing.T) {
+	// authenticate trusts the Caller-ID of the requests with an Authorization header to avoid signing tokens
+	authenticate := func(handler rest.HandlerFunc) rest.HandlerFunc {
+		return func(r *rest.Request) *rest.Response {
+			if r.Header.Get(rest.AuthorizationHeader) != "" {
+				r.Claims = rest.Claims{"sub": r.UserID}
+			}
+			return handler(r)
+		}
+	}
+
+	newRouter := func(config rest.RateLimitConfig) *rest.Router {
+		router := rest.NewRouter(logs.InitTest())
+		router.Use(authenticate)
+		router.Use(rest.RateLimitMiddleware(logs.InitTest(), config))
+		router.Get("/orders", func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		})
+		router.Get("/products", func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		})
+		return router
+	}
+
+	do := func(router *rest.Router, target, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if userID != "" {
+			req.Header.Set(rest.CallerIDHeader, userID)
+			req.Header.Set(rest.AuthorizationHeader, "Bearer token")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	main.Run("Token bucket allows bursts and refills", func(t *testing.T) {
+		router := newRouter(rest.RateLimitConfig{Limit: 1, Period: 50 * time.Millisecond, Burst: 2})
+
+		w := do(router, "/orders", "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Equal(t, "2", w.Header().Get(rest.RateLimitLimitHeader))
+		require.Equal(t, "1", w.Header().Get(rest.RateLimitRemainingHeader))
+		require.Equal(t, http.StatusNoContent, do(router, "/orders", "").Code)
+
+		w = do(router, "/orders", "")
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		require.Equal(t, "1", w.Header().Get(rest.RetryAfterHeader))
+		require.Equal(t, "0", w.Header().Get(rest.RateLimitRemainingHeader))
+		require.Contains(t, w.Body.String(), "rate_limit_exceeded")
+
+		time.Sleep(60 * time.Millisecond)
+		require.Equal(t, http.StatusNoContent, do(router, "/orders", "").Code)
+	})
+
+	main.Run("Sliding window allows the limit in the period", func(t *testing.T) {
+		router := newRouter(rest.RateLimitConfig{Algorithm: rest.SlidingWindow, Limit: 2, Period: time.Hour})
+
+		require.Equal(t, http.StatusNoContent, do(router, "/orders", "").Code)
+		w := do(router, "/orders", "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Equal(t, "0", w.Header().Get(rest.RateLimitRemainingHeader))
+
+		w = do(router, "/orders", "")
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		require.NotEmpty(t, w.Header().Get(rest.RetryAfterHeader))
+	})
+
+	main.Run("Requests are counted by key", func(t *testing.T) {
+		router := newRouter(rest.RateLimitConfig{Limit: 1, Period: time.Hour, Key: rest.KeyBy(rest.KeyByUserID, rest.KeyByRoute)})
+
+		require.Equal(t, http.StatusNoContent, do(router, "/orders", "user1").Code)
+		require.Equal(t, http.StatusTooManyRequests, do(router, "/orders", "user1").Code)
+		require.Equal(t, http.StatusNoContent, do(router, "/orders", "user2").Code)
+		require.Equal(t, http.StatusNoContent, do(router, "/products", "user1").Code)
+		require.Equal(t, http.StatusNoContent, do(router, "/orders", "").Code)
+	})
+
+	main.Run("Unauthenticated requests are counted by IP address", func(t *testing.T) {
+		router := newRouter(rest.RateLimitConfig{Limit: 1, Period: time.Hour, Key: rest.KeyByUserID})
+
+		spoof := func(userID string) int {
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.Header.Set(rest.CallerIDHeader, userID)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+		require.Equal(t, http.StatusNoContent, spoof("user1"))
+		require.Equal(t, http.StatusTooManyRequests, spoof("user2"))
+	})
+
+	main.Run("Requests fail closed when the store fails", func(t *testing.T) {
+		w := do(newRouter(rest.RateLimitConfig{Limit: 1, Period: time.Hour, Store: failingStore{}}), "/orders", "")
+		require.Equal(t, http.StatusServiceUnavailable, w.Code)
+		require.Contains(t, w.Body.String(), "rate_limit_unavailable")
+
+		router := newRouter(rest.RateLimitConfig{Limit: 1, Period: time.Hour, Store: failingStore{}, FailOpen: true})
+		require.Equal(t, http.StatusNoContent, do(router, "/orders", "").Code)
+	})
+
+	main.Run("Concurrent requests don't exceed the limit", func(t *testing.T) {
+		limiter := rest.NewRateLimiter(rest.RateLimitConfig{Limit: 5, Period: time.Hour})
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		allowed := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := limiter.Allow(context.Background(), "key")
+				require.NoError(t, err)
+				if res.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, 5, allowed)
+	})
+}
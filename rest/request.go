@@ -3,15 +3,14 @@ package rest
 import (
 	"encoding/json"
 	"fmt"
-	"go.uber.org/zap"
 	"io"
+	"mime"
 	"mime/multipart"
-	"net"
 	"net/http"
 	"path/filepath"
 	"reflect"
-	"runtime/debug"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/gonzispina/gokit/context"
@@ -62,26 +61,36 @@ const (
 	ImageJPG ContentType = "image/jpg"
 	// AudioMP4A content type
 	AudioMP4A ContentType = "audio/mp4"
+	// VideoMP4 content type
+	VideoMP4 ContentType = "video/mp4"
 	// ApplicationDocx content type
 	ApplicationDocx ContentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
 	// ApplicationDoc content type
 	ApplicationDoc ContentType = "application/msword"
 	// ApplicationTxt content type
 	ApplicationTxt ContentType = "text/plain"
+	// ApplicationXML content type
+	ApplicationXML ContentType = "application/xml"
+	// ApplicationJSONLines content type
+	ApplicationJSONLines ContentType = "application/jsonl"
+	// TextCSV content type
+	TextCSV ContentType = "text/csv"
+	// ApplicationProblemJSON content type
+	ApplicationProblemJSON ContentType = "application/problem+json"
+	// TextEventStream content type
+	TextEventStream ContentType = "text/event-stream"
 )
 
 // HandlerFunc implementation to isolate the web entry points from the framework
 // and make entry points more "testable"
 type HandlerFunc func(r *Request) *Response
 
-// NewResponse for all entry points
+// NewResponse for all entry points. When the content type header is not set
+// the data is encoded in the content type negotiated with the client
 func NewResponse(statusCode int, data interface{}, header map[string]string) *Response {
 	if header == nil {
 		header = map[string]string{}
 	}
-	if data != nil && header[ContentTypeHeader] == "" {
-		header[ContentTypeHeader] = string(ApplicationJSON)
-	}
 	return &Response{
 		Data:       data,
 		StatusCode: statusCode,
@@ -96,9 +105,12 @@ type Response struct {
 	Header     map[string]string `json:"-"`
 	Err        string            `json:"description"`
 	Code       string            `json:"code"`
+	Errors     []FieldError      `json:"errors,omitempty"`
+	TrackingID string            `json:"trackingId,omitempty"`
+	err        error
 }
 
-// File implementation
+// File implementation. Size is -1 when the file is streamed
 type File struct {
 	Name        string
 	Ext         string
@@ -116,9 +128,12 @@ type Request struct {
 	queryParams map[string]string
 	Body        io.Reader
 	File        *File
+	Files       map[string][]*File
 	ctx         context.Context
 	JSONBody    interface{}
 	Filter      *Filter
+	Claims      Claims
+	logger      logs.Logger
 }
 
 // Context of the request
@@ -153,8 +168,21 @@ func (r *Request) QueryParam(key string) string {
 	if r.Request == nil {
 		return ""
 	}
-	r.queryParams[key] = r.URL.Query().Get(key)
-	return r.queryParams[key]
+	return r.URL.Query().Get(key)
+}
+
+// QueryParams returns every value of a query param from the URL
+func (r *Request) QueryParams(key string) []string {
+	if value, found := r.queryParams[key]; found {
+		if value == "" {
+			return nil
+		}
+		return []string{value}
+	}
+	if r.Request == nil {
+		return nil
+	}
+	return r.URL.Query()[key]
 }
 
 // SetQueryParam adds a query param to the request
@@ -167,103 +195,54 @@ func (r *Request) SetQueryParam(key, value string) *Request {
 }
 
 // UpgradeMiddleware transforms a chi/http request into Request
-func UpgradeMiddleware(logger logs.Logger) func(handlerFunc HandlerFunc) http.HandlerFunc {
+func UpgradeMiddleware(logger logs.Logger, opts ...Option) func(handlerFunc HandlerFunc) http.HandlerFunc {
 	if logger == nil {
 		panic("logger must be initialized")
 	}
+	u := &upgrader{logger: logger, options: newOptions(opts...)}
 	return func(handler HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			rctx := chi.RouteContext(r.Context())
-			ctx := context.Upgrade(r.Context())
-
-			defer func() {
-				r := recover()
-				if r == nil {
-					return
-				}
-
-				logger.Error(ctx, "Recovered from panic", logs.Error(r.(error)), logs.Bytes(debug.Stack()))
-				w.WriteHeader(http.StatusInternalServerError)
-			}()
-
-			userID := r.Header.Get(CallerIDHeader)
-			routeParams := map[string]string{}
-			for i, key := range rctx.URLParams.Keys {
-				routeParams[key] = rctx.URLParams.Values[i]
-			}
-
-			ipAddress := r.RemoteAddr
-			if strings.Index(ipAddress, ":") > 0 {
-				host, _, err := net.SplitHostPort(ipAddress)
-				if err != nil {
-					w.WriteHeader(http.StatusBadRequest)
-					return
-				}
-				ipAddress = host
+			ctx := u.context(w, r)
+			rw := &responseWriter{ResponseWriter: w}
+			body := &countingReader{ReadCloser: r.Body}
+			if r.Body != nil {
+				r.Body = body
 			}
 
 			req := &Request{
-				UserID:      userID,
+				UserID:      r.Header.Get(CallerIDHeader),
 				Body:        r.Body,
-				RouteParams: routeParams,
+				RouteParams: map[string]string{},
 				queryParams: map[string]string{},
 				ctx:         ctx,
 				Filter:      &Filter{},
-				IPAddress:   ipAddress,
 				Request:     r,
+				logger:      logger,
 			}
 
-			res := handler(req)
-			if res.Header != nil {
-				for k, v := range res.Header {
-					w.Header().Add(k, v)
-				}
-			}
+			var res *Response
+			start := time.Now()
+			defer func() { u.accessLog(rw, req, body, res, start) }()
+			defer u.recover(rw, req, &res)
 
-			if res.Err != "" {
-				w.Header().Add(ContentTypeHeader, ApplicationJSON.String())
-				w.WriteHeader(res.StatusCode)
-				_ = json.NewEncoder(w).Encode(res)
-				return
-			}
-
-			if res.Data == nil {
-				w.WriteHeader(res.StatusCode)
-				return
+			for i, key := range rctx.URLParams.Keys {
+				req.RouteParams[key] = rctx.URLParams.Values[i]
 			}
 
-			if res.Header[ContentTypeHeader] == string(ApplicationJSON) || res.Header[ContentTypeHeader] == "" {
-				w.Header().Add(ContentTypeHeader, ApplicationJSON.String())
-				w.WriteHeader(res.StatusCode)
-				err := json.NewEncoder(w).Encode(&res.Data)
-				if err != nil {
-					logger.Error(ctx, "Couldn't marshal response", logs.Error(err), logs.UserID(userID))
-					w.WriteHeader(http.StatusInternalServerError)
-					return
-				}
-				return
+			if u.options.ipResolver != nil {
+				req.IPAddress = u.options.ipResolver.ClientIP(r)
+			} else {
+				req.IPAddress = remoteIP(r.RemoteAddr)
 			}
 
-			data, ok := res.Data.(io.ReadCloser)
-			if !ok {
-				logger.Error(ctx, "Cannot handle datatype", zap.String("type", reflect.TypeOf(res.Data).String()))
-				w.WriteHeader(http.StatusInternalServerError)
-				return
+			res = handler(req)
+			if res == nil {
+				logger.Error(ctx, "Handler returned a nil response", logs.UserID(req.UserID))
+				res = InternalServerError()
 			}
 
-			defer func() {
-				if err := data.Close(); err != nil {
-					logger.Error(ctx, "Couldn't close reader", logs.Error(err))
-				}
-			}()
-
-			w.Header().Add(ContentTypeHeader, res.Header[ContentTypeHeader])
-			w.WriteHeader(res.StatusCode)
-			_, err := io.Copy(w, data)
-			if err != nil {
-				logger.Error(ctx, "Couldn't write Data into response", logs.Error(err))
-				return
-			}
+			u.write(rw, req, res)
 		}
 	}
 }
@@ -280,28 +259,9 @@ func JSONMiddleware(logger logs.Logger) func(handler HandlerFunc, t reflect.Type
 				return handler(r)
 			}
 
-			contentType := r.Header.Get(ContentTypeHeader)
-			if contentType != "application/json" {
-				return BadRequest(errors.New("invalid content type", "invalid_content_type"))
-			}
-
-			value := reflect.New(t)
-			err := json.NewDecoder(r.Body).Decode(value.Interface())
-			if err != nil {
-				message := "invalid request body"
-				var jsonErr *json.UnmarshalTypeError
-				if errors.As(err, &jsonErr) {
-					message = fmt.Sprintf("field '%s' cannot be of type '%s'. It must be of type '%s'", jsonErr.Field, jsonErr.Value, jsonErr.Type.String())
-				}
-				return BadRequest(errors.New(message, "invalid_request_body"))
-			}
-
-			if err = validator.Value(value); err != nil {
-				if err == ErrLib {
-					logger.Error(r.Context(), "An error occurred in validator lib", logs.Error(err))
-					return InternalServerError()
-				}
-				return BadRequest(err)
+			value, res := decodeJSONBody(logger, r, t, validator)
+			if res != nil {
+				return res
 			}
 
 			r.JSONBody = value.Interface()
@@ -311,9 +271,45 @@ func JSONMiddleware(logger logs.Logger) func(handler HandlerFunc, t reflect.Type
 	}
 }
 
+// decodeJSONBody decodes the body of the request into a new value of type t and validates it.
+// It returns a non nil response when the body cannot be accepted
+func decodeJSONBody(logger logs.Logger, r *Request, t reflect.Type, validator *Validator) (reflect.Value, *Response) {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get(ContentTypeHeader))
+	if contentType != string(ApplicationJSON) {
+		return reflect.Value{}, BadRequest(errInvalidContentType)
+	}
+
+	value := reflect.New(t)
+	err := json.NewDecoder(r.Body).Decode(value.Interface())
+	if err != nil {
+		message := "invalid request body"
+		var jsonErr *json.UnmarshalTypeError
+		if errors.As(err, &jsonErr) {
+			message = fmt.Sprintf("field '%s' cannot be of type '%s'. It must be of type '%s'", jsonErr.Field, jsonErr.Value, jsonErr.Type.String())
+		}
+		return reflect.Value{}, BadRequest(errors.New(message, "invalid_request_body"))
+	}
+
+	if validator == nil {
+		return value, nil
+	}
+	if err = validator.Value(value, Languages(r)...); err != nil {
+		if err == ErrLib {
+			if logger != nil {
+				logger.Error(r.Context(), "An error occurred in validator lib", logs.Error(err))
+			}
+			return reflect.Value{}, InternalServerError()
+		}
+		return reflect.Value{}, BadRequest(err)
+	}
+
+	return value, nil
+}
+
 // UploadMiddleware validates that a request has a a valid content type and length
-// It returns 408 Entity Too Large if it the content length is not valid or 400 Bad Request if
-// the content type is not valid
+// It returns 413 Entity Too Large if the file is bigger than maxFileSize megabytes or 400 Bad Request if
+// the content type, detected from the first bytes of the file, is not valid.
+// The file is buffered in memory or in temporary files, see StreamingUploadMiddleware to avoid it
 func UploadMiddleware(logger logs.Logger) func(handler HandlerFunc, maxFileSize int64, t reflect.Type, validator *Validator, types ...ContentType) HandlerFunc {
 	return func(handler HandlerFunc, maxFileSize int64, t reflect.Type, validator *Validator, types ...ContentType) HandlerFunc {
 		if len(types) == 0 {
@@ -346,8 +342,17 @@ func UploadMiddleware(logger logs.Logger) func(handler HandlerFunc, maxFileSize
 				return BadRequest(errors.New("'data' form value must be present", "multipart_field_data_not_present"))
 			}
 
-			contentType := header.Header.Get(ContentTypeHeader)
-			if !contentTypes.Has(contentType) {
+			if header.Size > maxFileSize<<20 {
+				return RequestEntityTooLarge()
+			}
+
+			// The content type is detected from the content, the one sent by the client is not trusted
+			contentType, err := sniffFile(data)
+			if err != nil {
+				logger.Warn(r.Context(), "Couldn't read data", logs.Error(err))
+				return BadRequest(errors.New("invalid multipart body", "invalid_multipart_body"))
+			}
+			if !contentTypes.allows(contentType) {
 				return BadRequest(errors.New("content type must be one of: "+contentTypes.String(), "invalid_content_type"))
 			}
 
@@ -356,44 +361,16 @@ func UploadMiddleware(logger logs.Logger) func(handler HandlerFunc, maxFileSize
 				Size:        header.Size,
 				Ext:         filepath.Ext(header.Filename),
 				Data:        data,
-				ContentType: ContentType(contentType),
+				ContentType: contentType,
 			}
 
-			if t != nil {
-				value := reflect.New(t)
-				str := r.FormValue("params")
-				if len(str) != 0 {
-					err = json.Unmarshal([]byte(str), value.Interface())
-					if err != nil {
-						logger.Warn(r.Context(), "Invalid body params")
-						return BadRequest(errors.New("'params' must be a valid json", "multipart_invalid_field_params"))
-					}
-				} else {
-					params, header, err := r.FormFile("params")
-					if err != nil {
-						logger.Warn(r.Context(), "Invalid body params")
-						return BadRequest(errors.New("'params' field invalid", "multipart_invalid_field_params"))
-					}
-
-					contentType := header.Header.Get(ContentTypeHeader)
-					if contentType != string(ApplicationJSON) {
-						return BadRequest(errors.New("'params' content type invalid", "multipart_invalid_field_params"))
-					}
-
-					err = json.NewDecoder(params).Decode(value.Interface())
-					if err != nil {
-						return BadRequest(errors.New("'params' must be a valid json", "multipart_invalid_field_params"))
-					}
-				}
+			r.Files = map[string][]*File{"data": {r.File}}
 
-				if err = validator.Value(value); err != nil {
-					if err == ErrLib {
-						logger.Error(r.Context(), "An error occurred in validator lib", logs.Error(err))
-						return InternalServerError()
-					}
-					return BadRequest(err)
+			if t != nil {
+				value, res := multipartParams(logger, r, t, validator)
+				if res != nil {
+					return res
 				}
-
 				r.JSONBody = value.Interface()
 			}
 
@@ -401,3 +378,43 @@ func UploadMiddleware(logger logs.Logger) func(handler HandlerFunc, maxFileSize
 		}
 	}
 }
+
+// multipartParams decodes and validates the "params" field of a parsed multipart form into a new value of type t.
+// The field can be sent as a value or as a file with a JSON content type
+func multipartParams(logger logs.Logger, r *Request, t reflect.Type, validator *Validator) (reflect.Value, *Response) {
+	value := reflect.New(t)
+	str := r.FormValue("params")
+	if len(str) != 0 {
+		err := json.Unmarshal([]byte(str), value.Interface())
+		if err != nil {
+			logger.Warn(r.Context(), "Invalid body params")
+			return reflect.Value{}, BadRequest(errors.New("'params' must be a valid json", "multipart_invalid_field_params"))
+		}
+	} else {
+		params, header, err := r.FormFile("params")
+		if err != nil {
+			logger.Warn(r.Context(), "Invalid body params")
+			return reflect.Value{}, BadRequest(errors.New("'params' field invalid", "multipart_invalid_field_params"))
+		}
+
+		contentType := header.Header.Get(ContentTypeHeader)
+		if contentType != string(ApplicationJSON) {
+			return reflect.Value{}, BadRequest(errors.New("'params' content type invalid", "multipart_invalid_field_params"))
+		}
+
+		err = json.NewDecoder(params).Decode(value.Interface())
+		if err != nil {
+			return reflect.Value{}, BadRequest(errors.New("'params' must be a valid json", "multipart_invalid_field_params"))
+		}
+	}
+
+	if err := validator.Value(value, Languages(r)...); err != nil {
+		if err == ErrLib {
+			logger.Error(r.Context(), "An error occurred in validator lib", logs.Error(err))
+			return reflect.Value{}, InternalServerError()
+		}
+		return reflect.Value{}, BadRequest(err)
+	}
+
+	return value, nil
+}
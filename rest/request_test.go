@@ -0,0 +1,115 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonzispina/gokit/errors"
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeMiddlewareRecover(main *testing.T) {
+	var recovered []interface{}
+	router := rest.NewRouter(logs.InitTest(), rest.WithPanicHook(func(r *rest.Request, value interface{}, stack []byte) {
+		require.NotEmpty(main, stack)
+		recovered = append(recovered, value)
+	}))
+	router.Get("/string", func(r *rest.Request) *rest.Response {
+		panic("something went wrong")
+	})
+	router.Get("/streaming", func(r *rest.Request) *rest.Response {
+		events := make(chan rest.Event)
+		go func() {
+			events <- rest.Event{Data: "first"}
+			close(events)
+		}()
+		return rest.NewResponse(http.StatusOK, panicStreamer{events}, nil)
+	})
+
+	main.Run("Any panic value returns the error body with the tracking id", func(t *testing.T) {
+		recovered = nil
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/string", nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Equal(t, rest.ApplicationJSON.String(), w.Header().Get(rest.ContentTypeHeader))
+
+		var body rest.Response
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		require.Equal(t, errors.ErrUnknown.Code(), body.Code)
+		require.NotEmpty(t, body.TrackingID)
+		require.Equal(t, []interface{}{"something went wrong"}, recovered)
+	})
+
+	main.Run("The status is not written twice", func(t *testing.T) {
+		recovered = nil
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/streaming", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "data: first\n\n", w.Body.String())
+		require.Len(t, recovered, 1)
+	})
+}
+
+// panicStreamer panics after streaming its events
+type panicStreamer struct {
+	events chan rest.Event
+}
+
+func (s panicStreamer) Stream(w http.ResponseWriter, r *rest.Request, logger logs.Logger) {
+	for event := range s.events {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("data: " + event.Data.(string) + "\n\n"))
+	}
+	panic(http.ErrBodyNotAllowed)
+}
+
+func TestWithRequestID(main *testing.T) {
+	trusted := func(r *http.Request) bool { return r.Header.Get("X-Internal") == "true" }
+	router := rest.NewRouter(logs.InitTest(), rest.WithRequestID(rest.RequestIDConfig{Traceparent: true, Trusted: trusted}))
+	router.Get("/orders", func(r *rest.Request) *rest.Response {
+		return rest.OK(r.Context().TrackingID())
+	})
+
+	do := func(header map[string]string) (string, string) {
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set("X-Internal", "true")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var id string
+		require.NoError(main, json.NewDecoder(w.Body).Decode(&id))
+		return id, w.Header().Get(rest.RequestIDHeader)
+	}
+
+	main.Run("The received id is used and echoed", func(t *testing.T) {
+		id, echoed := do(map[string]string{rest.RequestIDHeader: "req-123"})
+		require.Equal(t, "req-123", id)
+		require.Equal(t, "req-123", echoed)
+	})
+
+	main.Run("The trace id of traceparent is used when there is no request id", func(t *testing.T) {
+		id, echoed := do(map[string]string{rest.TraceparentHeader: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"})
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", id)
+		require.Equal(t, id, echoed)
+	})
+
+	main.Run("A new id is generated for invalid or untrusted ids", func(t *testing.T) {
+		for _, header := range []map[string]string{
+			{rest.RequestIDHeader: "<script>"},
+			{rest.TraceparentHeader: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+			{rest.RequestIDHeader: "req-123", "X-Internal": "false"},
+			{},
+		} {
+			id, echoed := do(header)
+			require.Len(t, id, 36)
+			require.Equal(t, id, echoed)
+		}
+	})
+}
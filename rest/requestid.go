@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gonzispina/gokit/context"
+)
+
+const maxRequestIDLength = 128
+
+// RequestIDConfig of the tracking ids received from other services
+type RequestIDConfig struct {
+	// Header with the id of the request. It is also set in the response. X-Request-ID by default
+	Header string
+	// Traceparent uses the trace id of the W3C traceparent header when Header is not present
+	Traceparent bool
+	// Trusted tells whether the ids sent in the request can be used, i.e. only from internal networks.
+	// Every request is trusted when it is nil
+	Trusted func(r *http.Request) bool
+}
+
+// WithRequestID uses the id received in the request as the tracking id of the context, so it is kept
+// between services. A new id is generated when the request has none, it is not valid or it is not trusted
+func WithRequestID(config RequestIDConfig) Option {
+	if config.Header == "" {
+		config.Header = RequestIDHeader
+	}
+	return func(o *options) {
+		o.requestID = &config
+	}
+}
+
+// context of the request with its tracking id
+func (u *upgrader) context(w http.ResponseWriter, r *http.Request) context.Context {
+	config := u.options.requestID
+	if config == nil {
+		return context.Upgrade(r.Context())
+	}
+
+	var id string
+	if config.Trusted == nil || config.Trusted(r) {
+		id = requestID(r, config)
+	}
+
+	ctx := context.UpgradeWithID(r.Context(), id)
+	w.Header().Set(config.Header, ctx.TrackingID())
+	return ctx
+}
+
+func requestID(r *http.Request, config *RequestIDConfig) string {
+	if id := r.Header.Get(config.Header); id != "" {
+		if validRequestID(id) {
+			return id
+		}
+		return ""
+	}
+	if config.Traceparent {
+		return traceID(r.Header.Get(TraceparentHeader))
+	}
+	return ""
+}
+
+// validRequestID accepts ids of up to 128 letters, digits and the symbols "-_.:"
+func validRequestID(id string) bool {
+	if len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// traceID of a W3C traceparent header: version-traceid-parentid-flags.
+// It returns an empty string if the header is not valid
+func traceID(traceparent string) string {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 {
+		return ""
+	}
+	version, trace, parent, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return ""
+	}
+	if !isLowerHex(trace, 32) || !isLowerHex(parent, 16) || !isLowerHex(flags, 2) {
+		return ""
+	}
+	if trace == strings.Repeat("0", 32) || parent == strings.Repeat("0", 16) {
+		return ""
+	}
+	return trace
+}
+
+func isLowerHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,238 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+
+	"github.com/gonzispina/gokit/errors"
+	"github.com/gonzispina/gokit/logs"
+	"go.uber.org/zap"
+)
+
+type upgrader struct {
+	logger  logs.Logger
+	options *options
+}
+
+// write the response into the http response writer
+func (u *upgrader) write(w http.ResponseWriter, r *Request, res *Response) {
+	for k, v := range res.Header {
+		if k == ContentTypeHeader {
+			continue
+		}
+		w.Header().Add(k, v)
+	}
+
+	if res.Err != "" || (u.options.problemDetails && res.Data == nil && res.StatusCode >= http.StatusBadRequest) {
+		u.writeError(w, r, res)
+		return
+	}
+
+	if res.Data == nil {
+		w.WriteHeader(res.StatusCode)
+		return
+	}
+
+	if streamer, ok := res.Data.(Streamer); ok {
+		ctx, cancel := streamContext(r.Context())
+		defer cancel()
+		r.ctx = ctx
+		streamer.Stream(w, r, u.logger)
+		return
+	}
+
+	accept := r.Header.Get(AcceptHeader)
+	contentType := res.Header[ContentTypeHeader]
+	if contentType != "" && !Acceptable(accept, contentType) {
+		u.writeError(w, r, NotAcceptable())
+		return
+	}
+
+	if data, ok := res.Data.(io.Reader); ok {
+		u.copy(w, r, res.StatusCode, contentType, data)
+		return
+	}
+
+	if contentType != "" {
+		encoder, ok := u.options.encoders.Get(contentType)
+		if !ok {
+			u.logger.Error(r.Context(), "Cannot handle datatype", zap.String("type", reflect.TypeOf(res.Data).String()))
+			u.writeError(w, r, InternalServerError())
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := encoder.Encode(&buf, res.Data); err != nil {
+			u.logger.Error(r.Context(), "Couldn't marshal response", logs.Error(err), logs.UserID(r.UserID))
+			u.writeError(w, r, InternalServerError())
+			return
+		}
+		u.writeBuffer(w, r, res.StatusCode, contentType, &buf)
+		return
+	}
+
+	// The data is encoded with the best accepted encoder. The next one is only tried when the
+	// encoder cannot write the type of the data, e.g. a map cannot be written as XML
+	w.Header().Add(VaryHeader, AcceptHeader)
+	var buf bytes.Buffer
+	for _, encoder := range u.options.encoders.Candidates(accept) {
+		buf.Reset()
+		err := encoder.Encode(&buf, res.Data)
+		if err == nil {
+			u.writeBuffer(w, r, res.StatusCode, encoder.ContentType().String(), &buf)
+			return
+		}
+		if !errors.Is(err, ErrUnsupportedData) {
+			u.logger.Error(r.Context(), "Couldn't marshal response", logs.Error(err), logs.UserID(r.UserID))
+			u.writeError(w, r, InternalServerError())
+			return
+		}
+		u.logger.Debug(r.Context(), "Couldn't marshal response", zap.String("contentType", encoder.ContentType().String()), logs.Error(err))
+	}
+
+	u.logger.Warn(r.Context(), "None of the accepted content types can encode the response", zap.String("type", reflect.TypeOf(res.Data).String()), zap.String("accept", accept))
+	u.writeError(w, r, NotAcceptable())
+}
+
+func (u *upgrader) writeBuffer(w http.ResponseWriter, r *Request, statusCode int, contentType string, buf *bytes.Buffer) {
+	w.Header().Set(ContentTypeHeader, contentType)
+	w.WriteHeader(statusCode)
+	if _, err := buf.WriteTo(w); err != nil {
+		u.logger.Error(r.Context(), "Couldn't write Data into response", logs.Error(err))
+	}
+}
+
+func (u *upgrader) writeError(w http.ResponseWriter, r *Request, res *Response) {
+	if u.options.problemDetails {
+		w.Header().Set(ContentTypeHeader, ApplicationProblemJSON.String())
+		w.WriteHeader(res.StatusCode)
+		_ = json.NewEncoder(w).Encode(NewProblem(res, r.Context().TrackingID(), u.options.problemTypeURI))
+		return
+	}
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		// Server errors carry the tracking id so they can be found in the logs
+		res.TrackingID = r.Context().TrackingID()
+	}
+
+	w.Header().Set(ContentTypeHeader, ApplicationJSON.String())
+	w.WriteHeader(res.StatusCode)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// recover from a panic of the handler, replacing its response with an internal server error.
+// The error response is only written if the handler didn't start writing its own response
+func (u *upgrader) recover(w *responseWriter, r *Request, res **Response) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if recovered == http.ErrAbortHandler {
+		// Used to abort the response on purpose, it is handled by the http server
+		panic(recovered)
+	}
+
+	stack := debug.Stack()
+	u.logger.Error(r.Context(), "Recovered from panic", logs.Error(panicError(recovered)), logs.UserID(r.UserID), logs.Bytes(stack))
+
+	if u.options.panicHook != nil {
+		u.options.panicHook(r, recovered, stack)
+	}
+
+	w.panicked = true
+	*res = NewError(http.StatusInternalServerError, errors.ErrUnknown)
+	if w.wroteHeader || w.hijacked {
+		return
+	}
+	u.writeError(w, r, *res)
+}
+
+func panicError(recovered interface{}) error {
+	if err, ok := recovered.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", recovered)
+}
+
+// responseWriter keeps track of what was written into the response
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	written     int64
+	wroteHeader bool
+	hijacked    bool
+	panicked    bool
+}
+
+// WriteHeader only once
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.status = statusCode
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write the body, with a 200 status if no other was written
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush the buffered data to the client
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// Hijack the connection, used by the websockets
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer doesn't support hijacking")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the original response writer
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (u *upgrader) copy(w http.ResponseWriter, r *Request, statusCode int, contentType string, data io.Reader) {
+	if closer, ok := data.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				u.logger.Error(r.Context(), "Couldn't close reader", logs.Error(err))
+			}
+		}()
+	}
+
+	if contentType != "" {
+		w.Header().Set(ContentTypeHeader, contentType)
+	}
+	w.WriteHeader(statusCode)
+	if _, err := io.Copy(w, data); err != nil {
+		u.logger.Error(r.Context(), "Couldn't write Data into response", logs.Error(err))
+	}
+}
@@ -0,0 +1,210 @@
+// Package resttest provides utilities to test rest handlers and middlewares
+package resttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+// RequestBuilder builds requests for handlers and middlewares
+type RequestBuilder struct {
+	t           testing.TB
+	method      string
+	target      string
+	header      http.Header
+	query       url.Values
+	routeParams map[string]string
+	remoteAddr  string
+	userID      string
+	body        []byte
+	contentType string
+	fields      [][2]string
+	files       []file
+}
+
+type file struct {
+	field, name string
+	data        []byte
+}
+
+// NewRequest starts building a request with the given method and target
+func NewRequest(t testing.TB, method, target string) *RequestBuilder {
+	return &RequestBuilder{
+		t:           t,
+		method:      method,
+		target:      target,
+		header:      http.Header{},
+		query:       url.Values{},
+		routeParams: map[string]string{},
+	}
+}
+
+// Get request
+func Get(t testing.TB, target string) *RequestBuilder {
+	return NewRequest(t, http.MethodGet, target)
+}
+
+// Post request
+func Post(t testing.TB, target string) *RequestBuilder {
+	return NewRequest(t, http.MethodPost, target)
+}
+
+// Put request
+func Put(t testing.TB, target string) *RequestBuilder {
+	return NewRequest(t, http.MethodPut, target)
+}
+
+// Patch request
+func Patch(t testing.TB, target string) *RequestBuilder {
+	return NewRequest(t, http.MethodPatch, target)
+}
+
+// Delete request
+func Delete(t testing.TB, target string) *RequestBuilder {
+	return NewRequest(t, http.MethodDelete, target)
+}
+
+// Header adds a header to the request
+func (b *RequestBuilder) Header(key, value string) *RequestBuilder {
+	b.header.Add(key, value)
+	return b
+}
+
+// UserID of the caller. It is also sent in the Caller-ID header
+func (b *RequestBuilder) UserID(userID string) *RequestBuilder {
+	b.userID = userID
+	b.header.Set(rest.CallerIDHeader, userID)
+	return b
+}
+
+// RemoteAddr of the request, "192.0.2.1:1234" by default
+func (b *RequestBuilder) RemoteAddr(addr string) *RequestBuilder {
+	b.remoteAddr = addr
+	return b
+}
+
+// URLParam adds a route param. It is only used when the request is built with Build,
+// Serve takes them from the target
+func (b *RequestBuilder) URLParam(key, value string) *RequestBuilder {
+	b.routeParams[key] = value
+	return b
+}
+
+// QueryParam adds a query param to the target
+func (b *RequestBuilder) QueryParam(key string, values ...string) *RequestBuilder {
+	for _, v := range values {
+		b.query.Add(key, v)
+	}
+	return b
+}
+
+// JSON encodes the value as the body of the request
+func (b *RequestBuilder) JSON(v interface{}) *RequestBuilder {
+	body, err := json.Marshal(v)
+	require.NoError(b.t, err)
+	return b.Body(rest.ApplicationJSON.String(), body)
+}
+
+// Body of the request with its content type
+func (b *RequestBuilder) Body(contentType string, body []byte) *RequestBuilder {
+	b.body = body
+	b.contentType = contentType
+	return b
+}
+
+// FormValue adds a value to the multipart body of the request. Values are written before the files
+func (b *RequestBuilder) FormValue(key, value string) *RequestBuilder {
+	b.fields = append(b.fields, [2]string{key, value})
+	return b
+}
+
+// File adds a file to the multipart body of the request
+func (b *RequestBuilder) File(field, name string, data []byte) *RequestBuilder {
+	b.files = append(b.files, file{field: field, name: name, data: data})
+	return b
+}
+
+// HTTP builds the request of the standard lib
+func (b *RequestBuilder) HTTP() *http.Request {
+	target := b.target
+	if len(b.query) > 0 {
+		u, err := url.Parse(target)
+		require.NoError(b.t, err)
+		query := u.Query()
+		for k, v := range b.query {
+			query[k] = append(query[k], v...)
+		}
+		u.RawQuery = query.Encode()
+		target = u.String()
+	}
+
+	body, contentType := b.body, b.contentType
+	if len(b.fields) > 0 || len(b.files) > 0 {
+		body, contentType = b.multipart()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	r := httptest.NewRequest(b.method, target, reader)
+	for k, v := range b.header {
+		r.Header[k] = v
+	}
+	if contentType != "" {
+		r.Header.Set(rest.ContentTypeHeader, contentType)
+	}
+	if b.remoteAddr != "" {
+		r.RemoteAddr = b.remoteAddr
+	}
+	return r
+}
+
+// Build the request to call a handler or middleware directly
+func (b *RequestBuilder) Build() *rest.Request {
+	r := b.HTTP()
+
+	ipAddress, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ipAddress = r.RemoteAddr
+	}
+
+	req := &rest.Request{
+		Request:   r,
+		UserID:    b.userID,
+		IPAddress: ipAddress,
+		Body:      r.Body,
+		Filter:    &rest.Filter{},
+	}
+	for k, v := range b.routeParams {
+		req.SetURLParam(k, v)
+	}
+	return req
+}
+
+func (b *RequestBuilder) multipart() ([]byte, string) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, f := range b.fields {
+		require.NoError(b.t, writer.WriteField(f[0], f[1]))
+	}
+	for _, f := range b.files {
+		part, err := writer.CreateFormFile(f.field, f.name)
+		require.NoError(b.t, err)
+		_, err = part.Write(f.data)
+		require.NoError(b.t, err)
+	}
+	require.NoError(b.t, writer.Close())
+	return body.Bytes(), writer.FormDataContentType()
+}
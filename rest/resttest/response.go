@@ -0,0 +1,103 @@
+package resttest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+// ResponseAssertions over the response returned by a handler
+type ResponseAssertions struct {
+	t   testing.TB
+	res *rest.Response
+}
+
+// AssertResponse starts the assertions over a response. It fails if the response is nil
+func AssertResponse(t testing.TB, res *rest.Response) *ResponseAssertions {
+	require.NotNil(t, res, "handler returned a nil response")
+	return &ResponseAssertions{t: t, res: res}
+}
+
+// Status code of the response
+func (a *ResponseAssertions) Status(statusCode int) *ResponseAssertions {
+	require.Equal(a.t, statusCode, a.res.StatusCode, "unexpected status code")
+	return a
+}
+
+// HasHeader checks a header of the response
+func (a *ResponseAssertions) HasHeader(key, value string) *ResponseAssertions {
+	require.Equal(a.t, value, a.res.Header[key], "unexpected %s header", key)
+	return a
+}
+
+// ErrorCode of the response
+func (a *ResponseAssertions) ErrorCode(code string) *ResponseAssertions {
+	require.Equal(a.t, code, a.res.Code, "unexpected error code")
+	return a
+}
+
+// JSON compares the data of the response encoded as JSON with the expected one
+func (a *ResponseAssertions) JSON(expected string) *ResponseAssertions {
+	actual, err := json.Marshal(a.res.Data)
+	require.NoError(a.t, err)
+	require.JSONEq(a.t, expected, string(actual))
+	return a
+}
+
+// Recorder of a request served end to end
+type Recorder struct {
+	*httptest.ResponseRecorder
+	t testing.TB
+}
+
+// Serve registers the handler in a chi router with the given pattern and serves the request through
+// UpgradeMiddleware, as it would be in a real server. The route params are taken from the target
+func Serve(t testing.TB, pattern string, handler rest.HandlerFunc, b *RequestBuilder, opts ...rest.Option) *Recorder {
+	r := b.HTTP()
+
+	mux := chi.NewRouter()
+	mux.Method(r.Method, pattern, rest.UpgradeMiddleware(logs.InitTest(), opts...)(handler))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return &Recorder{ResponseRecorder: w, t: t}
+}
+
+// Status code of the response
+func (r *Recorder) Status(statusCode int) *Recorder {
+	require.Equal(r.t, statusCode, r.Code, "unexpected status code, body: %s", r.Body.String())
+	return r
+}
+
+// HasHeader checks a header of the response
+func (r *Recorder) HasHeader(key, value string) *Recorder {
+	require.Equal(r.t, value, r.Result().Header.Get(key), "unexpected %s header", key)
+	return r
+}
+
+// ErrorCode of the body. It works with the default error bodies and problem details
+func (r *Recorder) ErrorCode(code string) *Recorder {
+	var body struct {
+		Code string `json:"code"`
+	}
+	require.NoError(r.t, json.Unmarshal(r.Body.Bytes(), &body), "body is not a JSON error: %s", r.Body.String())
+	require.Equal(r.t, code, body.Code, "unexpected error code")
+	return r
+}
+
+// JSON compares the body with the expected one
+func (r *Recorder) JSON(expected string) *Recorder {
+	require.JSONEq(r.t, expected, r.Body.String())
+	return r
+}
+
+// Decode the JSON body into v
+func (r *Recorder) Decode(v interface{}) *Recorder {
+	require.NoError(r.t, json.NewDecoder(r.Body).Decode(v))
+	return r
+}
@@ -0,0 +1,88 @@
+package resttest_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/gonzispina/gokit/rest/resttest"
+	"github.com/stretchr/testify/require"
+)
+
+type order struct {
+	Product  string `json:"product" validate:"required"`
+	Quantity int    `json:"quantity" validate:"min=1"`
+}
+
+func TestRequestBuilder(main *testing.T) {
+	main.Run("Requests are built with every part set", func(t *testing.T) {
+		r := resttest.Post(t, "/orders").
+			UserID("user-1").
+			Header(rest.LanguageHeader, "es").
+			URLParam("orderId", "o-1").
+			QueryParam("status", "a", "b").
+			JSON(order{Product: "book", Quantity: 1}).
+			Build()
+
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "user-1", r.UserID)
+		require.Equal(t, "user-1", r.Header.Get(rest.CallerIDHeader))
+		require.Equal(t, "es", r.Header.Get(rest.LanguageHeader))
+		require.Equal(t, "192.0.2.1", r.IPAddress)
+		require.Equal(t, "o-1", r.URLParam("orderId"))
+		require.Equal(t, "a", r.QueryParam("status"))
+		require.Equal(t, []string{"a", "b"}, r.QueryParams("status"))
+		require.Equal(t, rest.ApplicationJSON.String(), r.Header.Get(rest.ContentTypeHeader))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"product":"book","quantity":1}`, string(body))
+	})
+
+	main.Run("Query params can be read from requests built by hand", func(t *testing.T) {
+		r := &rest.Request{Request: httptest.NewRequest(http.MethodGet, "/?a=1", nil)}
+		require.Equal(t, "1", r.QueryParam("a"))
+		require.Equal(t, "", r.QueryParam("missing"))
+	})
+
+	main.Run("Files are sent in a multipart body", func(t *testing.T) {
+		r := resttest.Post(t, "/files").
+			FormValue("params", `{"product":"book","quantity":1}`).
+			File("data", "book.pdf", []byte("%PDF-1.7\n")).
+			Build()
+
+		handler := rest.Upload(logs.InitTest(), rest.NewValidator(), 1, []rest.ContentType{rest.ApplicationPDF}, func(r *rest.Request, file *rest.File, params *order) *rest.Response {
+			return rest.OK(map[string]string{"name": file.Name, "product": params.Product})
+		})
+		resttest.AssertResponse(t, handler(r)).
+			Status(http.StatusOK).
+			JSON(`{"name":"book.pdf","product":"book"}`)
+	})
+}
+
+func TestServe(main *testing.T) {
+	validator := rest.NewValidator()
+	handler := rest.JSON(validator, func(r *rest.Request, body *order) *rest.Response {
+		return rest.Created(map[string]string{"id": r.URLParam("orderId"), "user": r.UserID})
+	})
+
+	main.Run("Handlers are served through chi and UpgradeMiddleware", func(t *testing.T) {
+		resttest.Serve(t, "/orders/{orderId}", handler, resttest.Put(t, "/orders/o-1").UserID("user-1").JSON(order{Product: "book", Quantity: 1})).
+			Status(http.StatusCreated).
+			HasHeader(rest.ContentTypeHeader, rest.ApplicationJSON.String()).
+			JSON(`{"id":"o-1","user":"user-1"}`)
+	})
+
+	main.Run("Error codes are read from the body", func(t *testing.T) {
+		resttest.Serve(t, "/orders/{orderId}", handler, resttest.Put(t, "/orders/o-1").JSON(order{Quantity: 1})).
+			Status(http.StatusBadRequest).
+			ErrorCode("param_is_required")
+
+		resttest.Serve(t, "/orders/{orderId}", handler, resttest.Put(t, "/orders/o-1").JSON(order{Quantity: 1}), rest.WithProblemDetails("")).
+			HasHeader(rest.ContentTypeHeader, rest.ApplicationProblemJSON.String()).
+			ErrorCode("param_is_required")
+	})
+}
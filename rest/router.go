@@ -0,0 +1,143 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/gonzispina/gokit/logs"
+)
+
+// Middleware decorates a HandlerFunc
+type Middleware func(handler HandlerFunc) HandlerFunc
+
+// Chain wraps the handler with the middlewares. The first middleware is the outermost one
+func Chain(handler HandlerFunc, middlewares ...Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Router registers HandlerFunc entry points on top of chi.
+// UpgradeMiddleware is applied once per route, after the group and route middlewares
+type Router struct {
+	mux          *chi.Mux
+	upgrade      func(handler HandlerFunc) http.HandlerFunc
+	prefix       string
+	middlewares  []Middleware
+	requirements []Requirement
+	routes       *[]Route
+}
+
+// NewRouter returns an empty router. The options are passed to UpgradeMiddleware
+func NewRouter(logger logs.Logger, opts ...Option) *Router {
+	return &Router{
+		mux:     chi.NewRouter(),
+		upgrade: UpgradeMiddleware(logger, opts...),
+		routes:  &[]Route{},
+	}
+}
+
+// Use adds middlewares to the routes registered afterwards in this router or group
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// UseHTTP adds http middlewares to the mux of the router. They run before the routing, so they see
+// every request including the ones without a route, like the CORS preflights. As in chi, they must be
+// added before registering any route and they apply to the whole router, not only to a group
+func (r *Router) UseHTTP(middlewares ...func(http.Handler) http.Handler) {
+	r.mux.Use(middlewares...)
+}
+
+// Group returns a router that shares the routes of its parent, prepends the prefix to
+// every pattern and wraps every handler with the parent's middlewares followed by the given ones
+func (r *Router) Group(prefix string, middlewares ...Middleware) *Router {
+	mws := make([]Middleware, 0, len(r.middlewares)+len(middlewares))
+	mws = append(mws, r.middlewares...)
+	mws = append(mws, middlewares...)
+	return &Router{
+		mux:          r.mux,
+		upgrade:      r.upgrade,
+		prefix:       joinPath(r.prefix, prefix),
+		middlewares:  mws,
+		requirements: r.requirements,
+		routes:       r.routes,
+	}
+}
+
+// Require returns a group, with the same prefix, whose routes must satisfy the requirement.
+// Unlike using Authorize as a middleware, the requirement is reported by Routes
+func (r *Router) Require(requirement Requirement) *Router {
+	g := r.Group("", Authorize(requirement))
+	g.requirements = append(append([]Requirement{}, r.requirements...), requirement)
+	return g
+}
+
+// Routes registered in the router and its groups with their requirements
+func (r *Router) Routes() []Route {
+	return append([]Route{}, *r.routes...)
+}
+
+// Method registers a handler for the method and pattern
+func (r *Router) Method(method, pattern string, handler HandlerFunc, middlewares ...Middleware) {
+	h := Chain(Chain(handler, middlewares...), r.middlewares...)
+	pattern = joinPath(r.prefix, pattern)
+	r.mux.Method(method, pattern, r.upgrade(h))
+	*r.routes = append(*r.routes, Route{Method: method, Pattern: pattern, Requirements: r.requirements})
+}
+
+// Get registers a GET handler
+func (r *Router) Get(pattern string, handler HandlerFunc, middlewares ...Middleware) {
+	r.Method(http.MethodGet, pattern, handler, middlewares...)
+}
+
+// Post registers a POST handler
+func (r *Router) Post(pattern string, handler HandlerFunc, middlewares ...Middleware) {
+	r.Method(http.MethodPost, pattern, handler, middlewares...)
+}
+
+// Put registers a PUT handler
+func (r *Router) Put(pattern string, handler HandlerFunc, middlewares ...Middleware) {
+	r.Method(http.MethodPut, pattern, handler, middlewares...)
+}
+
+// Patch registers a PATCH handler
+func (r *Router) Patch(pattern string, handler HandlerFunc, middlewares ...Middleware) {
+	r.Method(http.MethodPatch, pattern, handler, middlewares...)
+}
+
+// Delete registers a DELETE handler
+func (r *Router) Delete(pattern string, handler HandlerFunc, middlewares ...Middleware) {
+	r.Method(http.MethodDelete, pattern, handler, middlewares...)
+}
+
+// NotFound sets the handler used when no route matches
+func (r *Router) NotFound(handler HandlerFunc) {
+	r.mux.NotFound(r.upgrade(Chain(handler, r.middlewares...)))
+}
+
+// MethodNotAllowed sets the handler used when the route exists but not for the method
+func (r *Router) MethodNotAllowed(handler HandlerFunc) {
+	r.mux.MethodNotAllowed(r.upgrade(Chain(handler, r.middlewares...)))
+}
+
+// ServeHTTP implements http.Handler
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+func joinPath(prefix, pattern string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if pattern == "" || pattern == "/" {
+		if prefix == "" {
+			return "/"
+		}
+		return prefix
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		pattern = "/" + pattern
+	}
+	return prefix + pattern
+}
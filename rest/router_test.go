@@ -0,0 +1,78 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter(main *testing.T) {
+	logger := logs.InitTest()
+
+	trace := func(name string, calls *[]string) rest.Middleware {
+		return func(handler rest.HandlerFunc) rest.HandlerFunc {
+			return func(r *rest.Request) *rest.Response {
+				*calls = append(*calls, name)
+				return handler(r)
+			}
+		}
+	}
+
+	main.Run("Routes receive the URL params", func(t *testing.T) {
+		router := rest.NewRouter(logger)
+		router.Get("/orders/{orderId}", func(r *rest.Request) *rest.Response {
+			return rest.OK(map[string]string{"id": r.URLParam("orderId")})
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/123", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `{"id":"123"}`, w.Body.String())
+	})
+
+	main.Run("Groups prepend their prefix", func(t *testing.T) {
+		router := rest.NewRouter(logger)
+		v1 := router.Group("/v1")
+		v1.Group("/orders").Get("/", func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/orders", nil))
+		require.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	main.Run("Middlewares run from the outermost group to the route", func(t *testing.T) {
+		var calls []string
+		router := rest.NewRouter(logger)
+		router.Use(trace("root", &calls))
+		group := router.Group("/v1", trace("group", &calls))
+		group.Post("/orders", func(r *rest.Request) *rest.Response {
+			calls = append(calls, "handler")
+			return rest.Created(nil)
+		}, trace("route", &calls))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/orders", nil))
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.Equal(t, []string{"root", "group", "route", "handler"}, calls)
+	})
+
+	main.Run("Group middlewares do not leak to the parent", func(t *testing.T) {
+		var calls []string
+		router := rest.NewRouter(logger)
+		router.Group("/admin", trace("admin", &calls))
+		router.Get("/health", func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Empty(t, calls)
+	})
+}
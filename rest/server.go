@@ -0,0 +1,239 @@
+package rest
+
+import (
+	stdcontext "context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gonzispina/gokit/context"
+	"github.com/gonzispina/gokit/logs"
+	"go.uber.org/zap"
+)
+
+// ServerConfig of the http server. Zero values use the defaults
+type ServerConfig struct {
+	// Address to listen to, ":8080" by default
+	Address string
+	// TLSConfig used to serve HTTPS. CertFile and KeyFile can be used instead of its certificates
+	TLSConfig *tls.Config
+	CertFile  string
+	KeyFile   string
+	// ReadTimeout, ReadHeaderTimeout, WriteTimeout and IdleTimeout of the http server.
+	// Streaming endpoints (server-sent events and websockets) need a WriteTimeout of 0
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout is the time given to the in-flight requests to finish and, separately,
+	// to the shutdown hooks to run. 30 seconds by default
+	ShutdownTimeout time.Duration
+}
+
+// ShutdownHook releases a resource when the server shuts down
+type ShutdownHook func(ctx context.Context) error
+
+type shutdownHook struct {
+	name string
+	hook ShutdownHook
+}
+
+// streamsKey of the server streams in the context of the requests
+type streamsKey struct{}
+
+// streams of a server. shuttingDown is closed when the server starts shutting down and
+// active tracks the event streams and websockets, which the http server stops tracking once hijacked
+type streams struct {
+	shuttingDown <-chan struct{}
+	active       sync.WaitGroup
+}
+
+// streamContext derives a context that is also cancelled when the server starts shutting down,
+// so the streams end instead of holding the shutdown until ShutdownTimeout.
+// The stream is tracked by the server until cancel is called
+func streamContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	st, ok := ctx.Value(streamsKey{}).(*streams)
+	if !ok {
+		return ctx, cancel
+	}
+
+	st.active.Add(1)
+	go func() {
+		select {
+		case <-st.shuttingDown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var once sync.Once
+	return ctx, func() {
+		cancel()
+		once.Do(st.active.Done)
+	}
+}
+
+// Server serves a handler until the process receives SIGTERM or SIGINT and then shuts down gracefully:
+// it stops accepting connections, ends the streams, waits for the in-flight requests and runs the shutdown hooks
+type Server struct {
+	server  *http.Server
+	config  ServerConfig
+	logger  logs.Logger
+	mu      sync.Mutex
+	hooks   []shutdownHook
+	streams *streams
+}
+
+// NewServer for the handler, usually a Router
+func NewServer(logger logs.Logger, handler http.Handler, config ServerConfig) *Server {
+	if logger == nil {
+		panic("logger must be initialized")
+	}
+	if config.Address == "" {
+		config.Address = ":8080"
+	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = 30 * time.Second
+	}
+
+	shuttingDown, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		config:  config,
+		logger:  logger,
+		streams: &streams{shuttingDown: shuttingDown.Done()},
+		server: &http.Server{
+			Addr:              config.Address,
+			Handler:           handler,
+			TLSConfig:         config.TLSConfig,
+			ReadTimeout:       config.ReadTimeout,
+			ReadHeaderTimeout: config.ReadHeaderTimeout,
+			WriteTimeout:      config.WriteTimeout,
+			IdleTimeout:       config.IdleTimeout,
+		},
+	}
+	// The requests only see the shutdown through streamContext, the rest of them
+	// keep their context until they finish
+	s.server.BaseContext = func(net.Listener) stdcontext.Context {
+		return stdcontext.WithValue(stdcontext.Background(), streamsKey{}, s.streams)
+	}
+	s.server.RegisterOnShutdown(cancel)
+	return s
+}
+
+// OnShutdown registers a hook to run after the in-flight requests and streams finished, i.e. mongo.Close.
+// Hooks run in reverse order of registration
+func (s *Server) OnShutdown(name string, hook ShutdownHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, shutdownHook{name: name, hook: hook})
+}
+
+// Run listens to the configured address and serves until ctx is cancelled or the process
+// receives SIGTERM or SIGINT. It returns after the server shut down
+func (s *Server) Run(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.config.Address)
+	if err != nil {
+		s.logger.Error(ctx, "Couldn't listen", logs.Error(err), zap.String("address", s.config.Address))
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve works like Run with an existing listener
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	errs := make(chan error, 1)
+	go func() {
+		s.logger.Info(ctx, "Server started", zap.String("address", l.Addr().String()))
+		if s.config.TLSConfig != nil || s.config.CertFile != "" {
+			errs <- s.server.ServeTLS(l, s.config.CertFile, s.config.KeyFile)
+			return
+		}
+		errs <- s.server.Serve(l)
+	}()
+
+	select {
+	case err := <-errs:
+		s.logger.Error(ctx, "Server stopped unexpectedly", logs.Error(err))
+		s.runHooks()
+		return err
+	case sig := <-signals:
+		s.logger.Info(ctx, "Received signal, shutting down", zap.String("signal", sig.String()))
+	case <-ctx.Done():
+		s.logger.Info(ctx, "Context done, shutting down")
+	}
+
+	return s.Shutdown()
+}
+
+// Shutdown the server gracefully. It stops accepting connections, ends the event streams and websockets,
+// waits up to ShutdownTimeout for the in-flight requests and streams, closing the remaining requests after it,
+// and runs the shutdown hooks
+func (s *Server) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+
+	s.logger.Info(ctx, "Waiting for in-flight requests", zap.Duration("timeout", s.config.ShutdownTimeout))
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		s.logger.Error(ctx, "In-flight requests didn't finish in time, closing connections", logs.Error(err))
+		_ = s.server.Close()
+	} else {
+		s.logger.Info(ctx, "In-flight requests finished")
+	}
+
+	// The websockets are hijacked, so the http server doesn't wait for their handlers
+	streamsDone := make(chan struct{})
+	go func() {
+		s.streams.active.Wait()
+		close(streamsDone)
+	}()
+	select {
+	case <-streamsDone:
+	case <-ctx.Done():
+		s.logger.Error(ctx, "Streams didn't finish in time", logs.Error(ctx.Err()))
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+
+	if hookErr := s.runHooks(); err == nil {
+		err = hookErr
+	}
+
+	s.logger.Info(ctx, "Server shut down")
+	return err
+}
+
+// runHooks in reverse order, returning the first error
+func (s *Server) runHooks() error {
+	s.mu.Lock()
+	hooks := s.hooks
+	s.hooks = nil
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+
+	var first error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		s.logger.Info(ctx, "Running shutdown hook", zap.String("hook", h.name))
+		if err := h.hook(ctx); err != nil {
+			s.logger.Error(ctx, "Shutdown hook failed", zap.String("hook", h.name), logs.Error(err))
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
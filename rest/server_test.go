@@ -0,0 +1,172 @@
+package rest_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gonzispina/gokit/context"
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	logger := logs.InitTest()
+
+	started := make(chan struct{})
+	router := rest.NewRouter(logger)
+	router.Get("/slow", func(r *rest.Request) *rest.Response {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		return rest.OK("done")
+	})
+
+	var calls []string
+	server := rest.NewServer(logger, router, rest.ServerConfig{ShutdownTimeout: time.Second})
+	server.OnShutdown("mongo", func(ctx context.Context) error {
+		calls = append(calls, "mongo")
+		return nil
+	})
+	server.OnShutdown("cache", func(ctx context.Context) error {
+		calls = append(calls, "cache")
+		return nil
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- server.Serve(ctx, l) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		res, err := http.Get("http://" + l.Addr().String() + "/slow")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		responses <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	res := <-responses
+	require.NoError(t, res.err)
+	require.JSONEq(t, `"done"`, res.body)
+
+	require.NoError(t, <-stopped)
+	require.Equal(t, []string{"cache", "mongo"}, calls)
+
+	_, err = http.Get("http://" + l.Addr().String() + "/slow")
+	require.Error(t, err)
+}
+
+func TestServerShutdownEndsStreams(t *testing.T) {
+	logger := logs.InitTest()
+
+	router := rest.NewRouter(logger)
+	router.Get("/orders/status", func(r *rest.Request) *rest.Response {
+		return rest.SSE(make(chan rest.Event), time.Hour)
+	})
+	router.Get("/orders/live", rest.WebSocket(rest.WebSocketConfig{}, func(r *rest.Request, conn *rest.Conn) {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+
+	server := rest.NewServer(logger, router, rest.ServerConfig{ShutdownTimeout: 5 * time.Second})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- server.Serve(ctx, l) }()
+
+	res, err := http.Get("http://" + l.Addr().String() + "/orders/status")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+l.Addr().String()+"/orders/live", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	closed := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		closed <- err
+	}()
+
+	start := time.Now()
+	cancel()
+
+	_, err = io.Copy(io.Discard, res.Body)
+	require.NoError(t, err)
+	require.NoError(t, <-stopped)
+	require.Less(t, time.Since(start), time.Second)
+
+	err = <-closed
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway))
+	require.Contains(t, err.Error(), "server shutting down")
+}
+
+func TestServerShutdownWaitsForWebSockets(t *testing.T) {
+	logger := logs.InitTest()
+
+	var running int32
+	opened := make(chan struct{})
+	router := rest.NewRouter(logger)
+	router.Get("/orders/live", rest.WebSocket(rest.WebSocketConfig{}, func(r *rest.Request, conn *rest.Conn) {
+		atomic.StoreInt32(&running, 1)
+		defer atomic.StoreInt32(&running, 0)
+		close(opened)
+		<-conn.Context().Done()
+		// Releases the resources of the connection
+		time.Sleep(100 * time.Millisecond)
+	}))
+
+	server := rest.NewServer(logger, router, rest.ServerConfig{ShutdownTimeout: 5 * time.Second})
+	var runningOnShutdown int32 = -1
+	server.OnShutdown("mongo", func(ctx context.Context) error {
+		atomic.StoreInt32(&runningOnShutdown, atomic.LoadInt32(&running))
+		return nil
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- server.Serve(ctx, l) }()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+l.Addr().String()+"/orders/live", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	<-opened
+	cancel()
+
+	require.NoError(t, <-stopped)
+	require.Equal(t, int32(0), atomic.LoadInt32(&runningOnShutdown))
+}
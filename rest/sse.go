@@ -0,0 +1,176 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gonzispina/gokit/context"
+	"github.com/gonzispina/gokit/logs"
+)
+
+// DefaultHeartbeat is the interval between the heartbeats of an event stream when none is given
+const DefaultHeartbeat = 15 * time.Second
+
+// Streamer is implemented by the data of the responses that write the body themselves.
+// The upgrader hands them the response writer instead of encoding the data. The context
+// of the request is cancelled when the server starts shutting down
+type Streamer interface {
+	Stream(w http.ResponseWriter, r *Request, logger logs.Logger)
+}
+
+// Event of a server-sent events stream. Strings and byte slices are sent as they are
+// and any other data is encoded as JSON
+type Event struct {
+	ID    string
+	Event string
+	Data  interface{}
+	Retry time.Duration
+}
+
+// SSE returns a response that streams the events of the channel as server-sent events.
+// The stream ends when the channel is closed, the request context is cancelled or the server shuts down.
+// A heartbeat is sent every time the stream is idle for the given interval, DefaultHeartbeat when it is 0
+func SSE(events <-chan Event, heartbeat time.Duration) *Response {
+	return NewResponse(http.StatusOK, &eventStream{events: events, heartbeat: heartbeat}, nil)
+}
+
+// SSEFunc works like SSE but the events are pulled from next until it returns an error.
+// io.EOF ends the stream normally. next must return when the context is cancelled
+func SSEFunc(next func(ctx context.Context) (Event, error), heartbeat time.Duration) *Response {
+	return NewResponse(http.StatusOK, &eventStream{next: next, heartbeat: heartbeat}, nil)
+}
+
+type eventStream struct {
+	events    <-chan Event
+	next      func(ctx context.Context) (Event, error)
+	heartbeat time.Duration
+}
+
+// Stream the events into the response writer
+func (s *eventStream) Stream(w http.ResponseWriter, r *Request, logger logs.Logger) {
+	ctx := r.Context()
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		logger.Error(ctx, "Response writer doesn't support flushing, cannot stream events")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	events := s.events
+	if s.next != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		events = s.pull(ctx, logger)
+	}
+
+	heartbeat := s.heartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	w.Header().Set(ContentTypeHeader, TextEventStream.String())
+	w.Header().Set(CacheControlHeader, "no-cache")
+	w.Header().Set(ConnectionHeader, "keep-alive")
+	// Disables the buffering of nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	logger.Debug(ctx, "Event stream started", logs.UserID(r.UserID))
+	defer logger.Debug(ctx, "Event stream finished", logs.UserID(r.UserID))
+
+	var buf bytes.Buffer
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			buf.WriteString(": heartbeat\n\n")
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeEvent(&buf, event); err != nil {
+				logger.Error(ctx, "Couldn't encode event", logs.Error(err))
+				continue
+			}
+			ticker.Reset(heartbeat)
+		}
+
+		if _, err := buf.WriteTo(w); err != nil {
+			logger.Warn(ctx, "Couldn't write event, closing stream", logs.Error(err))
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// pull the events from next into a channel until it fails or the context is cancelled
+func (s *eventStream) pull(ctx context.Context, logger logs.Logger) <-chan Event {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		for {
+			event, err := s.next(ctx)
+			if err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					logger.Error(ctx, "Couldn't get next event", logs.Error(err))
+				}
+				return
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// writeEvent in the text/event-stream format
+func writeEvent(w *bytes.Buffer, event Event) error {
+	var data string
+	switch v := event.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+
+	if event.ID != "" {
+		fmt.Fprintf(w, "id: %s\n", stripNewlines(event.ID))
+	}
+	if event.Event != "" {
+		fmt.Fprintf(w, "event: %s\n", stripNewlines(event.Event))
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(w, "retry: %s\n", strconv.FormatInt(event.Retry.Milliseconds(), 10))
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", strings.TrimSuffix(line, "\r"))
+	}
+	w.WriteString("\n")
+	return nil
+}
+
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,54 @@
+package rest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gokitcontext "github.com/gonzispina/gokit/context"
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSE(main *testing.T) {
+	logger := logs.InitTest()
+
+	main.Run("Events are written in the event stream format", func(t *testing.T) {
+		router := rest.NewRouter(logger)
+		router.Get("/orders/{orderId}/status", func(r *rest.Request) *rest.Response {
+			events := make(chan rest.Event, 2)
+			events <- rest.Event{ID: "1", Event: "status", Data: map[string]string{"status": "paid"}, Retry: time.Second}
+			events <- rest.Event{Data: "first line\nsecond line"}
+			close(events)
+			return rest.SSE(events, 0)
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/1/status", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, rest.TextEventStream.String(), w.Header().Get(rest.ContentTypeHeader))
+		require.Equal(t, "no-cache", w.Header().Get(rest.CacheControlHeader))
+		require.Equal(t, "id: 1\nevent: status\nretry: 1000\ndata: {\"status\":\"paid\"}\n\n"+
+			"data: first line\ndata: second line\n\n", w.Body.String())
+	})
+
+	main.Run("Heartbeats are sent while idle and the stream stops with the request", func(t *testing.T) {
+		router := rest.NewRouter(logger)
+		router.Get("/orders/status", func(r *rest.Request) *rest.Response {
+			return rest.SSEFunc(func(ctx gokitcontext.Context) (rest.Event, error) {
+				<-ctx.Done()
+				return rest.Event{}, ctx.Err()
+			}, 10*time.Millisecond)
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+		defer cancel()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/status", nil).WithContext(ctx))
+		require.Contains(t, w.Body.String(), ": heartbeat\n\n")
+	})
+}
@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/gonzispina/gokit/logs"
+)
+
+// JSON decodes and validates the request body into a T before calling the handler.
+// Only structs and slices of structs are validated, other types like maps are just decoded.
+// GET and DELETE requests reach the handler with a nil body. Validator failures are
+// logged with the logger of the router.
+// It returns 400 Bad request if it cannot parse the JSON into T
+func JSON[T any](validator *Validator, handler func(r *Request, body *T) *Response) HandlerFunc {
+	t := typeOf[T]()
+	if !validatable(t) {
+		validator = nil
+	}
+	return func(r *Request) *Response {
+		if r.Method == http.MethodGet || r.Method == http.MethodDelete {
+			return handler(r, nil)
+		}
+
+		value, res := decodeJSONBody(r.logger, r, t, validator)
+		if res != nil {
+			return res
+		}
+
+		body := value.Interface().(*T)
+		r.JSONBody = body
+
+		return handler(r, body)
+	}
+}
+
+// Upload is the typed version of UploadMiddleware. The "params" field of the form
+// is decoded and validated into a T and passed to the handler along with the file
+func Upload[T any](logger logs.Logger, validator *Validator, maxFileSize int64, types []ContentType, handler func(r *Request, file *File, params *T) *Response) HandlerFunc {
+	next := func(r *Request) *Response {
+		params, _ := r.JSONBody.(*T)
+		return handler(r, r.File, params)
+	}
+	return UploadMiddleware(logger)(next, maxFileSize, typeOf[T](), validator, types...)
+}
+
+// UploadFiles is the typed version of FilesMiddleware. The files are passed to the handler
+// keyed by field name along with the "params" field decoded and validated into a T
+func UploadFiles[T any](logger logs.Logger, validator *Validator, fields []FileField, handler func(r *Request, files map[string][]*File, params *T) *Response) HandlerFunc {
+	next := func(r *Request) *Response {
+		params, _ := r.JSONBody.(*T)
+		return handler(r, r.Files, params)
+	}
+	return FilesMiddleware(logger)(next, typeOf[T](), validator, fields...)
+}
+
+// Body returns the decoded JSON body of the request as a T
+func Body[T any](r *Request) (*T, bool) {
+	body, ok := r.JSONBody.(*T)
+	return body, ok
+}
+
+// Reply returns a response with typed data
+func Reply[T any](statusCode int, data T) *Response {
+	return NewResponse(statusCode, data, nil)
+}
+
+// validatable tells whether the type can have validation rules: structs and slices of structs
+func validatable(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	return t.Kind() == reflect.Struct
+}
+
+func typeOf[T any]() reflect.Type {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Ptr {
+		panic(fmt.Sprintf("Concept %s cannot be a pointer", t.Elem()))
+	}
+	return t
+}
@@ -0,0 +1,101 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+type order struct {
+	Product  string `json:"product" validate:"required"`
+	Quantity int    `json:"quantity" validate:"min=1"`
+}
+
+func newJSONRequest(method, body string) *rest.Request {
+	r := httptest.NewRequest(method, "/orders", strings.NewReader(body))
+	r.Header.Set(rest.ContentTypeHeader, "application/json; charset=utf-8")
+	return &rest.Request{Request: r, Body: r.Body}
+}
+
+func TestJSON(main *testing.T) {
+	validator := rest.NewValidator()
+
+	main.Run("The handler receives the decoded body", func(t *testing.T) {
+		handler := rest.JSON(validator, func(r *rest.Request, body *order) *rest.Response {
+			require.Equal(t, "book", body.Product)
+			require.Equal(t, 2, body.Quantity)
+
+			stored, ok := rest.Body[order](r)
+			require.True(t, ok)
+			require.Same(t, body, stored)
+			return rest.Reply(http.StatusCreated, body)
+		})
+
+		res := handler(newJSONRequest(http.MethodPost, `{"product":"book","quantity":2}`))
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+	})
+
+	main.Run("Invalid bodies never reach the handler", func(t *testing.T) {
+		handler := rest.JSON(validator, func(r *rest.Request, body *order) *rest.Response {
+			t.Fatal("handler must not be called")
+			return nil
+		})
+
+		res := handler(newJSONRequest(http.MethodPost, `{"product":"book","quantity":0}`))
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res = handler(newJSONRequest(http.MethodPost, `{"product":1}`))
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "invalid_request_body", res.Code)
+	})
+
+	main.Run("GET requests receive a nil body", func(t *testing.T) {
+		handler := rest.JSON(validator, func(r *rest.Request, body *order) *rest.Response {
+			require.Nil(t, body)
+			return rest.NoContent()
+		})
+
+		res := handler(newJSONRequest(http.MethodGet, ""))
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+	})
+}
+
+func TestJSONLogsValidatorErrors(main *testing.T) {
+	logger := &recorder{}
+	router := rest.NewRouter(logger)
+	router.Post("/orders", rest.JSON(rest.NewValidator(), func(r *rest.Request, body *map[string]string) *rest.Response {
+		return rest.OK(*body)
+	}))
+	// The validator doesn't support time.Time as the root value
+	router.Post("/deliveries", rest.JSON(rest.NewValidator(), func(r *rest.Request, body *time.Time) *rest.Response {
+		return rest.NoContent()
+	}))
+
+	post := func(target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set(rest.ContentTypeHeader, "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	main.Run("Maps are not validated", func(t *testing.T) {
+		w := post("/orders", `{"product":"book"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `{"product":"book"}`, w.Body.String())
+		require.Empty(t, logger.entries)
+	})
+
+	main.Run("Validator errors are logged", func(t *testing.T) {
+		w := post("/deliveries", `"2022-05-01T10:00:00Z"`)
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Len(t, logger.entries, 1)
+		require.Equal(t, "error", logger.entries[0].level)
+		require.Equal(t, "An error occurred in validator lib", logger.entries[0].msg)
+	})
+}
@@ -0,0 +1,485 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/gonzispina/gokit/errors"
+	"github.com/gonzispina/gokit/logs"
+)
+
+// ErrFileTooLarge is returned while reading a streamed file that exceeds its size limit
+var ErrFileTooLarge = errors.New("file is too large", "file_too_large")
+
+const (
+	sniffLen = 512
+	// containerSniffLen is read to look into zip and OLE files, which need more than the first bytes
+	containerSniffLen = 64 << 10
+	maxParamsSize     = 1 << 20
+	multipartSlack    = 64 << 10
+	// defaultMaxMemory used to parse multipart forms, the rest of the files is stored in temporary files
+	defaultMaxMemory = 32 << 20
+)
+
+var (
+	zipSignature = []byte("PK\x03\x04")
+	oleSignature = []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")
+	// wordDocumentStream is the name, in UTF-16, of the stream of the Word 97-2003 documents
+	wordDocumentStream = []byte("W\x00o\x00r\x00d\x00D\x00o\x00c\x00u\x00m\x00e\x00n\x00t\x00")
+)
+
+// SniffContentType detects the content type from the first bytes of a file. Zip and OLE files
+// are only reported as docx and doc if the bytes contain the entries of a Word document
+func SniffContentType(head []byte) ContentType {
+	return sniffContentType(head, nil)
+}
+
+// sniffContentType detects the content type from the head of a file. The whole file is read from f when
+// it is available, otherwise f is nil
+func sniffContentType(head []byte, f io.ReaderAt) ContentType {
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return ApplicationPDF
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return ImagePNG
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return ImageJPEG
+	case bytes.HasPrefix(head, zipSignature):
+		if isDocx(head) {
+			return ApplicationDocx
+		}
+	case bytes.HasPrefix(head, oleSignature):
+		if isWordDocument(head, f) {
+			return ApplicationDoc
+		}
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		if contentType, ok := sniffMP4(head); ok {
+			return contentType
+		}
+	}
+	return ContentType(baseMediaType(http.DetectContentType(head)))
+}
+
+// isDocx tells whether the local file headers of a zip have the entries of an Office Open XML
+// Word document. They are at the beginning of the documents written by the office suites
+func isDocx(head []byte) bool {
+	var contentTypes, word bool
+	for i := 0; ; {
+		j := bytes.Index(head[i:], zipSignature)
+		if j < 0 {
+			break
+		}
+		i += j
+		if i+30 > len(head) {
+			break
+		}
+		nameLen := int(binary.LittleEndian.Uint16(head[i+26:]))
+		if i+30+nameLen > len(head) {
+			break
+		}
+		name := string(head[i+30 : i+30+nameLen])
+		contentTypes = contentTypes || name == "[Content_Types].xml"
+		word = word || strings.HasPrefix(name, "word/")
+		i += 30 + nameLen
+	}
+	return contentTypes && word
+}
+
+// sniffMP4 detects the content type of an MP4 file from the brands of its ftyp box, the major one and
+// the compatible ones. Audio files are often written with the generic brands, which are reported as VideoMP4
+func sniffMP4(head []byte) (ContentType, bool) {
+	size := int(binary.BigEndian.Uint32(head))
+	if size > len(head) {
+		size = len(head)
+	}
+	brands := []string{string(head[8:12])}
+	for i := 16; i+4 <= size; i += 4 {
+		brands = append(brands, string(head[i:i+4]))
+	}
+
+	for _, brand := range brands {
+		switch brand {
+		case "M4A ", "M4B ", "M4P ", "F4A ":
+			return AudioMP4A, true
+		}
+	}
+	switch brands[0] {
+	case "isom", "iso2", "iso3", "iso4", "iso5", "iso6", "mp41", "mp42", "dash":
+		return VideoMP4, true
+	}
+	return "", false
+}
+
+// isWordDocument tells whether an OLE compound file has the WordDocument stream by walking its directory
+// sectors. When only the head of the file is known, f is nil, and the directory is beyond the head
+// the file is taken as a document, since its header is valid
+func isWordDocument(head []byte, f io.ReaderAt) bool {
+	partial := f == nil
+	if partial {
+		f = bytes.NewReader(head)
+	}
+
+	header := make([]byte, 512)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return false
+	}
+	shift := binary.LittleEndian.Uint16(header[30:])
+	if shift != 9 && shift != 12 {
+		return false
+	}
+	sectorSize := int64(1) << shift
+	perFATSector := uint32(sectorSize / 4)
+
+	entry := make([]byte, 128)
+	next := make([]byte, 4)
+	sector := binary.LittleEndian.Uint32(header[48:])
+	// The chain is bounded in case the file is corrupted and has a loop
+	for i := 0; i < 1024 && sector < 0xfffffffa; i++ {
+		offset := (int64(sector) + 1) * sectorSize
+		for e := int64(0); e < sectorSize; e += 128 {
+			if _, err := f.ReadAt(entry, offset+e); err != nil {
+				return partial && err == io.EOF
+			}
+			if binary.LittleEndian.Uint16(entry[64:]) == 26 && bytes.Equal(entry[:24], wordDocumentStream) {
+				return true
+			}
+		}
+
+		// The header only lists the first 109 sectors of the allocation table
+		index := sector / perFATSector
+		if index >= 109 {
+			return false
+		}
+		fatSector := binary.LittleEndian.Uint32(header[76+4*index:])
+		if _, err := f.ReadAt(next, (int64(fatSector)+1)*sectorSize+int64(sector%perFATSector)*4); err != nil {
+			return partial && err == io.EOF
+		}
+		sector = binary.LittleEndian.Uint32(next)
+	}
+	return false
+}
+
+func sniffFile(f multipart.File) (ContentType, error) {
+	head := make([]byte, containerSniffLen)
+	n, err := f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return sniffContentType(head[:n], f), nil
+}
+
+// allows tells whether the detected content type is one of the array. Generic MP4
+// files are allowed as AudioMP4A, many recorders write the audio with their brands
+func (c ContentTypes) allows(contentType ContentType) bool {
+	for _, ct := range c {
+		if ct == contentType || (ct == ImageJPG && contentType == ImageJPEG) || (ct == AudioMP4A && contentType == VideoMP4) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedReader fails with ErrFileTooLarge when more than n bytes are read
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, ErrFileTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.n {
+		l.exceeded = true
+		return int(l.n), ErrFileTooLarge
+	}
+	l.n -= int64(n)
+	return n, err
+}
+
+// limitBody limits the body of the request to n bytes, like http.MaxBytesReader.
+// The returned reader tells whether the limit was exceeded
+func limitBody(r *Request, n int64) *limitedReader {
+	limited := &limitedReader{r: r.Request.Body, n: n}
+	r.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{limited, r.Request.Body}
+	return limited
+}
+
+// StreamingUploadMiddleware works like UploadMiddleware but reads the multipart body as it arrives,
+// without buffering the file. The "params" field must be sent before the "data" file, which the
+// handler reads from File.Data. maxFileSize is the exact limit in bytes: reading past it fails
+// with ErrFileTooLarge and the request is answered with 413 Entity Too Large.
+// The content type is detected from the first bytes of the file
+func StreamingUploadMiddleware(logger logs.Logger) func(handler HandlerFunc, maxFileSize int64, t reflect.Type, validator *Validator, types ...ContentType) HandlerFunc {
+	return func(handler HandlerFunc, maxFileSize int64, t reflect.Type, validator *Validator, types ...ContentType) HandlerFunc {
+		if len(types) == 0 {
+			panic("content type array cannot be empty")
+		}
+		if maxFileSize < 1 {
+			panic("max file size must be > 0")
+		}
+		if t != nil && t.Kind() == reflect.Ptr {
+			panic(fmt.Sprintf("Concept %s cannot be a pointer", t.Elem()))
+		}
+
+		contentTypes := ContentTypes(types)
+		return func(r *Request) *Response {
+			if r.Method != http.MethodPost {
+				return handler(r)
+			}
+
+			maxBodySize := maxFileSize + maxParamsSize + multipartSlack
+			if r.ContentLength > maxBodySize {
+				return RequestEntityTooLarge()
+			}
+			body := limitBody(r, maxBodySize)
+			reader, err := r.MultipartReader()
+			if err != nil {
+				return BadRequest(errors.New("invalid multipart body", "invalid_multipart_body"))
+			}
+
+			for {
+				part, err := reader.NextPart()
+				if err == io.EOF {
+					return BadRequest(errors.New("'data' form value must be present", "multipart_field_data_not_present"))
+				}
+				if err != nil {
+					if body.exceeded {
+						return RequestEntityTooLarge()
+					}
+					logger.Warn(r.Context(), "Couldn't read multipart body", logs.Error(err))
+					return BadRequest(errors.New("invalid multipart body", "invalid_multipart_body"))
+				}
+
+				switch part.FormName() {
+				case "params":
+					if t == nil {
+						continue
+					}
+					value, res := decodeParams(logger, r, part, t, validator)
+					if body.exceeded {
+						return RequestEntityTooLarge()
+					}
+					if res != nil {
+						return res
+					}
+					r.JSONBody = value.Interface()
+					continue
+				case "data":
+					if part.FileName() == "" {
+						continue
+					}
+				default:
+					continue
+				}
+
+				if t != nil && r.JSONBody == nil {
+					return BadRequest(errors.New("'params' field invalid", "multipart_invalid_field_params"))
+				}
+
+				limited := &limitedReader{r: part, n: maxFileSize}
+				data := bufio.NewReaderSize(limited, containerSniffLen)
+				head, err := data.Peek(sniffLen)
+				if err == nil && (bytes.HasPrefix(head, zipSignature) || bytes.HasPrefix(head, oleSignature)) {
+					head, err = data.Peek(containerSniffLen)
+				}
+				if limited.exceeded || body.exceeded {
+					return RequestEntityTooLarge()
+				}
+				if err != nil && err != io.EOF {
+					logger.Warn(r.Context(), "Couldn't read data", logs.Error(err))
+					return BadRequest(errors.New("invalid multipart body", "invalid_multipart_body"))
+				}
+
+				contentType := SniffContentType(head)
+				if !contentTypes.allows(contentType) {
+					return BadRequest(errors.New("content type must be one of: "+contentTypes.String(), "invalid_content_type"))
+				}
+
+				r.File = &File{
+					Name:        part.FileName(),
+					Size:        -1,
+					Ext:         filepath.Ext(part.FileName()),
+					Data:        data,
+					ContentType: contentType,
+				}
+
+				res := handler(r)
+				if limited.exceeded || body.exceeded {
+					return RequestEntityTooLarge()
+				}
+				return res
+			}
+		}
+	}
+}
+
+// decodeParams decodes and validates the json of a multipart field
+func decodeParams(logger logs.Logger, r *Request, params io.Reader, t reflect.Type, validator *Validator) (reflect.Value, *Response) {
+	value := reflect.New(t)
+	if err := json.NewDecoder(io.LimitReader(params, maxParamsSize)).Decode(value.Interface()); err != nil {
+		logger.Warn(r.Context(), "Invalid body params")
+		return reflect.Value{}, BadRequest(errors.New("'params' must be a valid json", "multipart_invalid_field_params"))
+	}
+
+	if err := validator.Value(value, Languages(r)...); err != nil {
+		if err == ErrLib {
+			logger.Error(r.Context(), "An error occurred in validator lib", logs.Error(err))
+			return reflect.Value{}, InternalServerError()
+		}
+		return reflect.Value{}, BadRequest(err)
+	}
+
+	return value, nil
+}
+
+// FileField describes a file field of a multipart request
+type FileField struct {
+	// Name of the form field
+	Name string
+	// MaxCount of files the field accepts, 1 when it is 0
+	MaxCount int
+	// MaxSize of each file in bytes
+	MaxSize int64
+	// Types the files can have, detected from their first bytes
+	Types []ContentType
+	// Required fields must have at least one file
+	Required bool
+}
+
+func (f FileField) maxCount() int64 {
+	if f.MaxCount < 1 {
+		return 1
+	}
+	return int64(f.MaxCount)
+}
+
+// FilesMiddleware validates the files of every declared field of a multipart request and
+// leaves them in Request.Files keyed by field name. Files sent in fields that were not declared are ignored.
+// The files are closed and removed when the handler returns, so they must not be read after it.
+// It returns 413 Entity Too Large if a file is bigger than the MaxSize of its field or 400 Bad Request if
+// a field has too many files, misses a required file or a file has a content type that is not allowed
+func FilesMiddleware(logger logs.Logger) func(handler HandlerFunc, t reflect.Type, validator *Validator, fields ...FileField) HandlerFunc {
+	return func(handler HandlerFunc, t reflect.Type, validator *Validator, fields ...FileField) HandlerFunc {
+		if len(fields) == 0 {
+			panic("file fields cannot be empty")
+		}
+		if t != nil && t.Kind() == reflect.Ptr {
+			panic(fmt.Sprintf("Concept %s cannot be a pointer", t.Elem()))
+		}
+
+		maxBodySize := int64(maxParamsSize + multipartSlack)
+		for _, field := range fields {
+			if field.Name == "" {
+				panic("file field name cannot be empty")
+			}
+			if len(field.Types) == 0 {
+				panic(fmt.Sprintf("content type array of field %s cannot be empty", field.Name))
+			}
+			if field.MaxSize < 1 {
+				panic(fmt.Sprintf("max file size of field %s must be > 0", field.Name))
+			}
+			maxBodySize += field.MaxSize * field.maxCount()
+		}
+
+		return func(r *Request) *Response {
+			if r.Method != http.MethodPost {
+				return handler(r)
+			}
+
+			if r.ContentLength > maxBodySize {
+				return RequestEntityTooLarge()
+			}
+			body := limitBody(r, maxBodySize)
+
+			if err := r.ParseMultipartForm(defaultMaxMemory); err != nil {
+				if errors.Is(err, multipart.ErrMessageTooLarge) || body.exceeded {
+					return RequestEntityTooLarge()
+				}
+				return BadRequest(errors.New("invalid multipart body", "invalid_multipart_body"))
+			}
+			// The files stored in disk are removed once the request is answered
+			defer r.MultipartForm.RemoveAll()
+
+			var opened []multipart.File
+			defer func() {
+				for _, f := range opened {
+					f.Close()
+				}
+			}()
+
+			files := map[string][]*File{}
+			for _, field := range fields {
+				headers := r.MultipartForm.File[field.Name]
+				if len(headers) == 0 {
+					if field.Required {
+						return BadRequest(errors.New(fmt.Sprintf("'%s' form value must be present", field.Name), "multipart_field_not_present"))
+					}
+					continue
+				}
+				if int64(len(headers)) > field.maxCount() {
+					return BadRequest(errors.New(fmt.Sprintf("'%s' cannot have more than %d files", field.Name, field.maxCount()), "multipart_too_many_files"))
+				}
+
+				contentTypes := ContentTypes(field.Types)
+				for _, header := range headers {
+					if header.Size > field.MaxSize {
+						return RequestEntityTooLarge()
+					}
+
+					data, err := header.Open()
+					if err != nil {
+						logger.Warn(r.Context(), "Couldn't open file", logs.Error(err))
+						return BadRequest(errors.New("invalid multipart body", "invalid_multipart_body"))
+					}
+					opened = append(opened, data)
+
+					contentType, err := sniffFile(data)
+					if err != nil {
+						logger.Warn(r.Context(), "Couldn't read file", logs.Error(err))
+						return BadRequest(errors.New("invalid multipart body", "invalid_multipart_body"))
+					}
+					if !contentTypes.allows(contentType) {
+						return BadRequest(errors.New(fmt.Sprintf("content type of '%s' must be one of: %s", field.Name, contentTypes), "invalid_content_type"))
+					}
+
+					files[field.Name] = append(files[field.Name], &File{
+						Name:        header.Filename,
+						Size:        header.Size,
+						Ext:         filepath.Ext(header.Filename),
+						Data:        data,
+						ContentType: contentType,
+					})
+				}
+			}
+			r.Files = files
+
+			if t != nil {
+				value, res := multipartParams(logger, r, t, validator)
+				if res != nil {
+					return res
+				}
+				r.JSONBody = value.Interface()
+			}
+
+			return handler(r)
+		}
+	}
+}
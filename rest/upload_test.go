@@ -0,0 +1,353 @@
+package rest_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func newMultipartRequest(t *testing.T, params string, filename string, data []byte) *rest.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if params != "" {
+		require.NoError(t, writer.WriteField("params", params))
+	}
+	part, err := writer.CreateFormFile("data", filename)
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/files", body)
+	r.Header.Set(rest.ContentTypeHeader, writer.FormDataContentType())
+	return &rest.Request{Request: r, Body: r.Body, Filter: &rest.Filter{}}
+}
+
+// zipFile returns a zip with empty entries
+func zipFile(t *testing.T, names ...string) []byte {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for _, name := range names {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte("<xml/>"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// oleFile returns a compound file with 512 bytes sectors: the directory in dirSector, with the
+// root entry and a stream, followed by the sectors of the allocation table
+func oleFile(stream string, dirSector int) []byte {
+	fatSectors := (dirSector+3)/128 + 1
+	f := make([]byte, 512*(dirSector+fatSectors+2))
+	copy(f, "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")
+	binary.LittleEndian.PutUint16(f[30:], 9)
+	binary.LittleEndian.PutUint32(f[48:], uint32(dirSector))
+	for i := 0; i < fatSectors; i++ {
+		binary.LittleEndian.PutUint32(f[76+4*i:], uint32(dirSector+1+i))
+	}
+
+	for i, name := range []string{"Root Entry", stream} {
+		entry := f[512*(dirSector+1)+i*128:]
+		for j, c := range name {
+			binary.LittleEndian.PutUint16(entry[j*2:], uint16(c))
+		}
+		binary.LittleEndian.PutUint16(entry[64:], uint16(len(name)*2+2))
+	}
+
+	fat := func(sector int, next uint32) {
+		fatSector := dirSector + 1 + sector/128
+		binary.LittleEndian.PutUint32(f[512*(fatSector+1)+4*(sector%128):], next)
+	}
+	fat(dirSector, 0xfffffffe)
+	for i := 0; i < fatSectors; i++ {
+		fat(dirSector+1+i, 0xfffffffd)
+	}
+	return f
+}
+
+func TestSniffContentType(t *testing.T) {
+	require.Equal(t, rest.ApplicationPDF, rest.SniffContentType([]byte("%PDF-1.7\n")))
+	require.Equal(t, rest.ImagePNG, rest.SniffContentType(pngHeader))
+	require.Equal(t, rest.ImageJPEG, rest.SniffContentType([]byte("\xff\xd8\xff\xe0\x00\x10JFIF")))
+	require.Equal(t, rest.AudioMP4A, rest.SniffContentType([]byte("\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00")))
+	require.Equal(t, rest.ApplicationTxt, rest.SniffContentType([]byte("plain old text")))
+
+	require.Equal(t, rest.ApplicationDocx, rest.SniffContentType(zipFile(t, "[Content_Types].xml", "_rels/.rels", "word/document.xml")))
+	require.Equal(t, rest.ApplicationDoc, rest.SniffContentType(oleFile("WordDocument", 0)))
+
+	// Other zip, OLE and MP4 files don't pass as documents or audio
+	require.Equal(t, rest.ContentType("application/zip"), rest.SniffContentType(zipFile(t, "photo.jpg")))
+	require.Equal(t, rest.ContentType("application/zip"), rest.SniffContentType(zipFile(t, "[Content_Types].xml", "xl/workbook.xml")))
+	require.NotEqual(t, rest.ApplicationDoc, rest.SniffContentType(oleFile("Workbook", 0)))
+
+	// MP4 files are audio if any of their brands is, otherwise the generic brands are reported as video
+	require.Equal(t, rest.AudioMP4A, rest.SniffContentType([]byte("\x00\x00\x00\x1cftypmp42\x00\x00\x00\x00mp42isomM4A ")))
+	require.Equal(t, rest.VideoMP4, rest.SniffContentType([]byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")))
+	require.Equal(t, rest.VideoMP4, rest.SniffContentType([]byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomiso2")))
+}
+
+func TestStreamingUploadMiddleware(main *testing.T) {
+	logger := logs.InitTest()
+	validator := rest.NewValidator()
+	middleware := rest.StreamingUploadMiddleware(logger)
+
+	main.Run("The file is streamed to the handler with the detected content type", func(t *testing.T) {
+		var received []byte
+		handler := middleware(func(r *rest.Request) *rest.Response {
+			require.Equal(t, rest.ImagePNG, r.File.ContentType)
+			require.Equal(t, int64(-1), r.File.Size)
+			require.Equal(t, "order", r.JSONBody.(*order).Product)
+
+			var err error
+			received, err = io.ReadAll(r.File.Data)
+			require.NoError(t, err)
+			return rest.NoContent()
+		}, 1024, reflect.TypeOf(order{}), validator, rest.ImagePNG)
+
+		data := append(pngHeader, bytes.Repeat([]byte{1}, 100)...)
+		res := handler(newMultipartRequest(t, `{"product":"order","quantity":1}`, "image.png", data))
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+		require.Equal(t, data, received)
+	})
+
+	main.Run("The declared extension does not change the detected content type", func(t *testing.T) {
+		handler := middleware(func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		}, 1024, nil, validator, rest.ImagePNG)
+
+		res := handler(newMultipartRequest(t, "", "image.png", []byte("%PDF-1.7\n")))
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "invalid_content_type", res.Code)
+	})
+
+	main.Run("Files over the limit are rejected with 413", func(t *testing.T) {
+		handler := middleware(func(r *rest.Request) *rest.Response {
+			_, err := io.ReadAll(r.File.Data)
+			require.ErrorIs(t, err, rest.ErrFileTooLarge)
+			return rest.NoContent()
+		}, 1024, nil, validator, rest.ImagePNG)
+
+		data := append(pngHeader, bytes.Repeat([]byte{1}, 2048)...)
+		res := handler(newMultipartRequest(t, "", "image.png", data))
+		require.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+	})
+
+	main.Run("Bodies over the limit are rejected with 413", func(t *testing.T) {
+		handler := middleware(func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		}, 1024, nil, validator, rest.ImagePNG)
+
+		newRequest := func() *rest.Request {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			require.NoError(t, writer.WriteField("other", string(bytes.Repeat([]byte("a"), 2<<20))))
+			require.NoError(t, writer.Close())
+			r := httptest.NewRequest(http.MethodPost, "/files", body)
+			r.Header.Set(rest.ContentTypeHeader, writer.FormDataContentType())
+			return &rest.Request{Request: r, Body: r.Body, Filter: &rest.Filter{}}
+		}
+
+		require.Equal(t, http.StatusRequestEntityTooLarge, handler(newRequest()).StatusCode)
+
+		// Without Content-Length the limit is found while reading
+		r := newRequest()
+		r.ContentLength = -1
+		require.Equal(t, http.StatusRequestEntityTooLarge, handler(r).StatusCode)
+	})
+
+	main.Run("Word documents are detected while streaming", func(t *testing.T) {
+		handler := middleware(func(r *rest.Request) *rest.Response {
+			return rest.OK(r.File.ContentType)
+		}, 1<<20, nil, validator, rest.ApplicationDocx)
+
+		res := handler(newMultipartRequest(t, "", "cv.docx", zipFile(t, "[Content_Types].xml", "word/document.xml")))
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		res = handler(newMultipartRequest(t, "", "cv.docx", zipFile(t, "cv.pdf")))
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "invalid_content_type", res.Code)
+	})
+
+	main.Run("Word 97-2003 documents are detected while streaming", func(t *testing.T) {
+		handler := middleware(func(r *rest.Request) *rest.Response {
+			return rest.OK(r.File.ContentType)
+		}, 1<<20, nil, validator, rest.ApplicationDoc)
+
+		// The directory of big documents is beyond the bytes that are sniffed
+		doc := oleFile("WordDocument", 130)
+		require.Greater(t, len(doc), 64<<10)
+		res := handler(newMultipartRequest(t, "", "cv.doc", doc))
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, rest.ApplicationDoc, res.Data)
+
+		res = handler(newMultipartRequest(t, "", "cv.doc", oleFile("Workbook", 0)))
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "invalid_content_type", res.Code)
+	})
+
+	main.Run("Audio with the generic MP4 brands is allowed", func(t *testing.T) {
+		handler := middleware(func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		}, 1<<20, nil, validator, rest.AudioMP4A)
+
+		res := handler(newMultipartRequest(t, "", "note.m4a", []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomiso2")))
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+	})
+}
+
+func TestUploadMiddleware(main *testing.T) {
+	logger := logs.InitTest()
+	validator := rest.NewValidator()
+	middleware := rest.UploadMiddleware(logger)
+
+	main.Run("The file and the params are left in the request", func(t *testing.T) {
+		handler := middleware(func(r *rest.Request) *rest.Response {
+			require.Equal(t, "image.png", r.File.Name)
+			require.Equal(t, ".png", r.File.Ext)
+			require.Equal(t, rest.ImagePNG, r.File.ContentType)
+			require.Equal(t, []*rest.File{r.File}, r.Files["data"])
+			require.Equal(t, "order", r.JSONBody.(*order).Product)
+			return rest.NoContent()
+		}, 1, reflect.TypeOf(order{}), validator, rest.ImagePNG)
+
+		res := handler(newMultipartRequest(t, `{"product":"order","quantity":1}`, "image.png", pngHeader))
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+	})
+
+	main.Run("The content type is detected from the content", func(t *testing.T) {
+		handler := middleware(func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		}, 1, nil, validator, rest.ImagePNG)
+
+		res := handler(newMultipartRequest(t, "", "image.png", []byte("%PDF-1.7\n")))
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "invalid_content_type", res.Code)
+	})
+
+	main.Run("The whole file is read to detect Word 97-2003 documents", func(t *testing.T) {
+		handler := middleware(func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		}, 1, nil, validator, rest.ApplicationDoc)
+
+		res := handler(newMultipartRequest(t, "", "cv.doc", oleFile("WordDocument", 130)))
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		res = handler(newMultipartRequest(t, "", "cv.doc", oleFile("Workbook", 130)))
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "invalid_content_type", res.Code)
+	})
+
+	main.Run("Files over the limit are rejected with 413", func(t *testing.T) {
+		handler := middleware(func(r *rest.Request) *rest.Response {
+			return rest.NoContent()
+		}, 1, nil, validator, rest.ImagePNG)
+
+		res := handler(newMultipartRequest(t, "", "image.png", append(pngHeader, bytes.Repeat([]byte{1}, 1<<20)...)))
+		require.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+	})
+}
+
+func TestFilesMiddleware(main *testing.T) {
+	logger := logs.InitTest()
+	validator := rest.NewValidator()
+
+	type part struct {
+		field, filename string
+		data            []byte
+	}
+	newRequest := func(t *testing.T, parts ...part) *rest.Request {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		require.NoError(t, writer.WriteField("params", `{"product":"id","quantity":1}`))
+		for _, p := range parts {
+			w, err := writer.CreateFormFile(p.field, p.filename)
+			require.NoError(t, err)
+			_, err = w.Write(p.data)
+			require.NoError(t, err)
+		}
+		require.NoError(t, writer.Close())
+
+		r := httptest.NewRequest(http.MethodPost, "/documents", body)
+		r.Header.Set(rest.ContentTypeHeader, writer.FormDataContentType())
+		return &rest.Request{Request: r, Body: r.Body, Filter: &rest.Filter{}}
+	}
+
+	pdf := []byte("%PDF-1.7\n")
+	fields := []rest.FileField{
+		{Name: "images", MaxCount: 2, MaxSize: 64, Types: []rest.ContentType{rest.ImagePNG, rest.ImageJPG}, Required: true},
+		{Name: "document", MaxSize: 64, Types: []rest.ContentType{rest.ApplicationPDF}},
+	}
+	handler := rest.UploadFiles(logger, validator, fields, func(r *rest.Request, files map[string][]*rest.File, params *order) *rest.Response {
+		return rest.OK(files)
+	})
+
+	main.Run("Files are grouped by field", func(t *testing.T) {
+		res := handler(newRequest(t,
+			part{"images", "front.png", pngHeader},
+			part{"images", "back.jpg", []byte("\xff\xd8\xff\xe0")},
+			part{"document", "form.pdf", pdf},
+		))
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		files := res.Data.(map[string][]*rest.File)
+		require.Len(t, files["images"], 2)
+		require.Equal(t, rest.ImagePNG, files["images"][0].ContentType)
+		require.Equal(t, rest.ImageJPEG, files["images"][1].ContentType)
+		require.Equal(t, "form.pdf", files["document"][0].Name)
+	})
+
+	main.Run("Each field enforces its own limits", func(t *testing.T) {
+		res := handler(newRequest(t, part{"document", "form.pdf", pdf}))
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "multipart_field_not_present", res.Code)
+
+		res = handler(newRequest(t, part{"images", "1.png", pngHeader}, part{"images", "2.png", pngHeader}, part{"images", "3.png", pngHeader}))
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "multipart_too_many_files", res.Code)
+
+		res = handler(newRequest(t, part{"images", "front.png", pngHeader}, part{"document", "form.pdf", pngHeader}))
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, "invalid_content_type", res.Code)
+
+		res = handler(newRequest(t, part{"images", "front.png", append(pngHeader, bytes.Repeat([]byte{1}, 64)...)}))
+		require.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+
+		// Without Content-Length the limit is found while reading
+		r := newRequest(t, part{"images", "front.png", bytes.Repeat(pngHeader, 1<<17)})
+		r.ContentLength = -1
+		require.Equal(t, http.StatusRequestEntityTooLarge, handler(r).StatusCode)
+	})
+
+	main.Run("Files are closed and removed when the handler returns", func(t *testing.T) {
+		var file *os.File
+		handler := rest.FilesMiddleware(logger)(func(r *rest.Request) *rest.Response {
+			file = r.Files["document"][0].Data.(*os.File)
+			return rest.NoContent()
+		}, nil, validator, rest.FileField{Name: "document", MaxSize: 64 << 20, Types: []rest.ContentType{rest.ApplicationPDF}})
+
+		// Files bigger than the memory used to parse the form are stored in disk
+		res := handler(newRequest(t, part{"document", "form.pdf", append(pdf, make([]byte, 33<<20)...)}))
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		_, err := file.Read(make([]byte, 1))
+		require.ErrorIs(t, err, os.ErrClosed)
+		_, err = os.Stat(file.Name())
+		require.True(t, os.IsNotExist(err))
+	})
+}
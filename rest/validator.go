@@ -1,10 +1,14 @@
 package rest
 
 import (
-	"fmt"
 	"reflect"
 	"strings"
 
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/pt"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	"github.com/gonzispina/gokit/errors"
 )
@@ -12,31 +16,113 @@ import (
 // ErrLib used in border cases
 var ErrLib = errors.New("unexpected library behaviour", "internal_library_error")
 
+// FieldLevel shadow so the API remains the same
+type FieldLevel = validator.FieldLevel
+
+// StructLevel shadow so the API remains the same
+type StructLevel = validator.StructLevel
+
 // Validator representation
 type Validator struct {
-	validator *validator.Validate
+	validator  *validator.Validate
+	translator *ut.UniversalTranslator
+	codes      map[string]string
 }
 
 // NewValidator retrieve a Validator pointer.
 func NewValidator() *Validator {
 	validate := validator.New()
 
-	// register function to get tag name from json tags.
+	// register function to get tag name from json tags, or from the param tags used by Bind.
 	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
-		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
-		if name == "-" {
-			return ""
+		for _, tag := range []string{"json", "path", "query", "header"} {
+			name := strings.SplitN(fld.Tag.Get(tag), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
 		}
-		return name
+		return ""
 	})
 
+	// English is the fallback language
+	translator := ut.New(en.New(), en.New(), es.New(), pt.New())
+	for locale, messages := range defaultMessages {
+		trans, _ := translator.GetTranslator(locale)
+		for code, text := range messages {
+			if err := trans.Add(code, text, false); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	codes := map[string]string{}
+	for tag, code := range defaultCodes {
+		codes[tag] = code
+	}
+
 	return &Validator{
-		validator: validate,
+		validator:  validate,
+		translator: translator,
+		codes:      codes,
+	}
+}
+
+// RegisterRule registers a validation tag with the function that checks it, the error code
+// it produces and its English message. The message receives the path of the field as {0}
+// and the parameter of the rule as {1}
+func (v *Validator) RegisterRule(tag string, fn func(fl FieldLevel) bool, code, message string) error {
+	if err := v.validator.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+	return v.RegisterCode(tag, code, message)
+}
+
+// RegisterCode sets the error code and the English message of a tag without registering a function.
+// It is meant for the tags reported by struct rules
+func (v *Validator) RegisterCode(tag, code, message string) error {
+	if err := v.AddTranslation("en", code, message); err != nil {
+		return err
+	}
+	v.codes[tag] = code
+	return nil
+}
+
+// RegisterStructRule registers a function that validates the whole struct of the given types.
+// Failures are reported with StructLevel.ReportError using a tag registered with RegisterCode, i.e.
+//
+//	sl.ReportError(b.EndDate, "endDate", "EndDate", "after_start_date", "")
+func (v *Validator) RegisterStructRule(fn func(sl StructLevel), types ...interface{}) {
+	v.validator.RegisterStructValidation(fn, types...)
+}
+
+// AddLocale adds a language to the validator so translations can be registered for it
+func (v *Validator) AddLocale(locale locales.Translator) error {
+	return v.translator.AddTranslator(locale, false)
+}
+
+// AddTranslation registers the message of an error code for a locale, overriding any existing one.
+// The message receives the path of the field as {0} and the parameter of the rule as {1}
+func (v *Validator) AddTranslation(locale, code, message string) error {
+	trans, found := v.translator.GetTranslator(locale)
+	if !found {
+		return errors.New("locale '"+locale+"' is not registered", "validator_unknown_locale")
 	}
+	return trans.Add(code, message, true)
+}
+
+// LoadTranslations imports a file, or every json file in a directory, with translations in the
+// universal-translator format: [{"locale": "es", "key": "param_is_required", "trans": "'{0}' es obligatorio"}]
+func (v *Validator) LoadTranslations(path string) error {
+	return v.translator.Import(ut.FormatJSON, path)
 }
 
 // Value is used to validate an interface fields values.
-func (v *Validator) Value(value reflect.Value) errors.Error {
+// The returned error is a *ValidationError with every field that failed.
+// The messages are written in the first of the languages that is supported, English otherwise
+func (v *Validator) Value(value reflect.Value, languages ...string) errors.Error {
 	var err error
 
 	kind := reflect.Indirect(value).Kind()
@@ -60,35 +146,158 @@ func (v *Validator) Value(value reflect.Value) errors.Error {
 		return ErrLib
 	}
 
-	e := errs[0]
-	var message string
-	var code string
-	switch e.Tag() {
-	case "required":
-		code = "param_is_required"
-		message = fmt.Sprintf("'%s' is required", e.Field())
-	case "len":
-		code = "param_invalid_length"
-		message = fmt.Sprintf("'%s' is must have a length equal to %s", e.Field(), e.Param())
-	case "min":
-		code = "param_length_below_minimum"
-		message = fmt.Sprintf("'%s' is has a minimun length of %s", e.Field(), e.Param())
-	case "max":
-		code = "param_length_over_maximum"
-		message = fmt.Sprintf("'%s' is has a maximum length of %s", e.Field(), e.Param())
-	case "oneof":
-		code = "param_is_not_present_in_enum"
-		message = fmt.Sprintf("'%s' must be one of: '%s'", e.Field(), strings.Join(strings.Split(e.Param(), " "), "' '"))
-	case "email":
-		code = "param_is_not_an_email"
-		message = fmt.Sprintf("'%s' must be a valid email address", e.Field())
-	case "unique":
-		code = "param_repeated_values"
-		message = fmt.Sprintf("'%s' does not allow repeated values", e.Field())
-	default:
-		code = "param_is_invalid"
-		message = fmt.Sprintf("'%s' is invalid, must meet the requirements of the tag %s", e.Field(), e.Tag())
+	fields := make([]FieldError, 0, len(errs))
+	for _, e := range errs {
+		fields = append(fields, v.fieldError(value.Type(), e, fieldPath(e, kind == reflect.Struct), languages))
 	}
 
-	return errors.New(message, code)
+	return &ValidationError{
+		err:    errors.New(fields[0].Message, fields[0].Code),
+		Fields: fields,
+	}
+}
+
+// fieldPath returns the path of the field built from the json tag names, i.e. items[2].price
+func fieldPath(e validator.FieldError, isStruct bool) string {
+	path := e.Namespace()
+	if isStruct {
+		// The namespace starts with the name of the validated struct
+		if i := strings.IndexAny(path, ".["); i >= 0 {
+			path = path[i:]
+		}
+	}
+	return strings.TrimPrefix(path, ".")
+}
+
+func (v *Validator) fieldError(root reflect.Type, e validator.FieldError, path string, languages []string) FieldError {
+	code, ok := v.codes[e.Tag()]
+	if !ok {
+		code = codeParamIsInvalid
+	}
+
+	param := e.Param()
+	switch {
+	case code == codeParamIsInvalid:
+		param = e.Tag()
+	case e.Tag() == "oneof":
+		param = "'" + strings.Join(strings.Fields(param), "' '") + "'"
+	case crossFieldTags[e.Tag()]:
+		param = siblingName(root, e.StructNamespace(), param)
+	}
+
+	return FieldError{
+		Field:   path,
+		Rule:    e.Tag(),
+		Param:   e.Param(),
+		Code:    code,
+		Message: v.translate(code, languages, path, param),
+	}
+}
+
+// siblingName returns the json name of the field that a cross field rule points to
+func siblingName(root reflect.Type, structNamespace, name string) string {
+	t := root
+	segments := strings.Split(structNamespace, ".")
+	for i, segment := range segments[:len(segments)-1] {
+		t = elemType(t)
+		if i == 0 && t.Kind() == reflect.Struct && !strings.HasPrefix(segment, "[") {
+			// The namespace starts with the name of the validated struct
+			continue
+		}
+		if j := strings.Index(segment, "["); j >= 0 {
+			segment = segment[:j]
+		}
+		if segment == "" {
+			continue
+		}
+		f, ok := t.FieldByName(segment)
+		if !ok {
+			return name
+		}
+		t = f.Type
+	}
+
+	t = elemType(t)
+	if t.Kind() != reflect.Struct {
+		return name
+	}
+	f, ok := t.FieldByName(name)
+	if !ok {
+		return name
+	}
+	if tag := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]; tag != "" && tag != "-" {
+		return tag
+	}
+	return name
+}
+
+func elemType(t reflect.Type) reflect.Type {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return t
+		}
+	}
+}
+
+// translate the message of the code into the first language that has it
+func (v *Validator) translate(code string, languages []string, params ...string) string {
+	for _, lang := range languages {
+		trans, found := v.translator.GetTranslator(lang)
+		if !found {
+			continue
+		}
+		if message, err := trans.T(code, params...); err == nil {
+			return message
+		}
+	}
+
+	if message, err := v.translator.GetFallback().T(code, params...); err == nil {
+		return message
+	}
+
+	message, _ := v.translator.GetFallback().T(codeParamIsInvalid, params...)
+	return message
+}
+
+// FieldError describes a field that failed a validation rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned by the Validator with the fields that failed
+type ValidationError struct {
+	err    errors.Error
+	Fields []FieldError
+}
+
+// Error message
+func (e *ValidationError) Error() string {
+	return e.err.Error()
+}
+
+// Code of the error
+func (e *ValidationError) Code() string {
+	return e.err.Code()
+}
+
+// Wrap an error
+func (e *ValidationError) Wrap(err error) errors.Error {
+	return &ValidationError{err: e.err.Wrap(err), Fields: e.Fields}
+}
+
+// Unwrap the error
+func (e *ValidationError) Unwrap() error {
+	return e.err.Unwrap()
+}
+
+// Is tells if the error is or not equal
+func (e *ValidationError) Is(err error) bool {
+	return e.err.Is(err)
 }
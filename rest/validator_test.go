@@ -0,0 +1,166 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/locales/pt_BR"
+	"github.com/gonzispina/gokit/errors"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/stretchr/testify/require"
+)
+
+type invoiceItem struct {
+	Price int      `json:"price" validate:"min=1"`
+	Tags  []string `json:"tags" validate:"dive,max=3"`
+}
+
+type invoice struct {
+	Customer string        `json:"customer" validate:"required"`
+	Items    []invoiceItem `json:"items" validate:"required,dive"`
+}
+
+type payment struct {
+	Currency string `json:"currency" validate:"currency"`
+}
+
+type booking struct {
+	StartDate time.Time `json:"startDate"`
+	EndDate   time.Time `json:"endDate" validate:"gtfield=StartDate"`
+	Guests    int       `json:"guests"`
+	Rooms     int       `json:"rooms"`
+}
+
+func TestValidator(main *testing.T) {
+	validator := rest.NewValidator()
+
+	fieldsOf := func(t *testing.T, err errors.Error) []rest.FieldError {
+		var validationErr *rest.ValidationError
+		require.True(t, errors.As(err, &validationErr))
+		return validationErr.Fields
+	}
+
+	main.Run("Value returns nil when the value is valid", func(t *testing.T) {
+		err := validator.Value(reflect.ValueOf(&invoice{Customer: "c", Items: []invoiceItem{{Price: 1}}}))
+		require.Nil(t, err)
+	})
+
+	main.Run("Value reports every failed field with its path", func(t *testing.T) {
+		err := validator.Value(reflect.ValueOf(&invoice{
+			Items: []invoiceItem{{Price: 1}, {Price: 1}, {Price: 0, Tags: []string{"long tag"}}},
+		}))
+		require.NotNil(t, err)
+		require.Equal(t, "param_is_required", err.Code())
+
+		fields := fieldsOf(t, err)
+		require.Len(t, fields, 3)
+		require.Equal(t, rest.FieldError{Field: "customer", Rule: "required", Code: "param_is_required", Message: fields[0].Message}, fields[0])
+		require.Equal(t, "items[2].price", fields[1].Field)
+		require.Equal(t, "min", fields[1].Rule)
+		require.Equal(t, "1", fields[1].Param)
+		require.Equal(t, "param_length_below_minimum", fields[1].Code)
+		require.Equal(t, "items[2].tags[0]", fields[2].Field)
+		require.Equal(t, "param_length_over_maximum", fields[2].Code)
+	})
+
+	main.Run("Slices are validated element by element", func(t *testing.T) {
+		err := validator.Value(reflect.ValueOf(&[]invoiceItem{{Price: 1}, {Price: 0}}))
+		require.NotNil(t, err)
+
+		fields := fieldsOf(t, err)
+		require.Len(t, fields, 1)
+		require.Equal(t, "[1].price", fields[0].Field)
+	})
+
+	main.Run("Messages are translated to the first supported language", func(t *testing.T) {
+		value := reflect.ValueOf(&invoice{Items: []invoiceItem{{Price: 1}}})
+
+		err := validator.Value(value, "fr", "es_AR", "es")
+		require.Equal(t, "'customer' es obligatorio", err.Error())
+
+		err = validator.Value(value, "pt")
+		require.Equal(t, "'customer' é obrigatório", err.Error())
+
+		err = validator.Value(value, "fr")
+		require.Equal(t, "'customer' is required", err.Error())
+	})
+
+	main.Run("Teams can load their own translations", func(t *testing.T) {
+		v := rest.NewValidator()
+		require.NoError(t, v.AddLocale(pt_BR.New()))
+
+		path := filepath.Join(t.TempDir(), "pt_BR.json")
+		content := `[{"locale": "pt_BR", "key": "param_is_required", "trans": "'{0}' é obrigatório, por favor"}]`
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		require.NoError(t, v.LoadTranslations(path))
+
+		value := reflect.ValueOf(&invoice{Items: []invoiceItem{{Price: 0}}})
+		err := v.Value(value, "pt_BR", "pt")
+
+		fields := fieldsOf(t, err)
+		require.Equal(t, "'customer' é obrigatório, por favor", fields[0].Message)
+		require.Equal(t, "'items[0].price' tem um comprimento mínimo de 1", fields[1].Message)
+	})
+
+	main.Run("Languages are taken from the request headers", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set(rest.AcceptLanguageHeader, "en;q=0.5, pt-BR, es;q=0.8")
+		require.Equal(t, []string{"pt_BR", "pt", "es", "en"}, rest.Languages(&rest.Request{Request: r}))
+
+		r.Header.Set(rest.LanguageHeader, "es")
+		require.Equal(t, []string{"es", "pt_BR", "pt", "es", "en"}, rest.Languages(&rest.Request{Request: r}))
+	})
+
+	main.Run("Cross field rules have their own codes", func(t *testing.T) {
+		now := time.Now()
+		err := validator.Value(reflect.ValueOf(&booking{StartDate: now, EndDate: now.Add(-time.Hour)}))
+
+		fields := fieldsOf(t, err)
+		require.Len(t, fields, 1)
+		require.Equal(t, "endDate", fields[0].Field)
+		require.Equal(t, "param_must_be_greater_than_field", fields[0].Code)
+		require.Equal(t, "'endDate' must be greater than 'startDate'", fields[0].Message)
+	})
+
+	main.Run("Custom rules produce their code and message", func(t *testing.T) {
+		v := rest.NewValidator()
+		err := v.RegisterRule("currency", func(fl rest.FieldLevel) bool {
+			return len(fl.Field().String()) == 3 && strings.ToUpper(fl.Field().String()) == fl.Field().String()
+		}, "param_is_not_a_currency", "'{0}' must be an ISO 4217 currency code")
+		require.NoError(t, err)
+		require.NoError(t, v.AddTranslation("es", "param_is_not_a_currency", "'{0}' debe ser un código de moneda ISO 4217"))
+
+		value := reflect.ValueOf(&payment{Currency: "ars"})
+		fields := fieldsOf(t, v.Value(value))
+		require.Len(t, fields, 1)
+		require.Equal(t, "currency", fields[0].Rule)
+		require.Equal(t, "param_is_not_a_currency", fields[0].Code)
+		require.Equal(t, "'currency' must be an ISO 4217 currency code", fields[0].Message)
+
+		fields = fieldsOf(t, v.Value(value, "es"))
+		require.Equal(t, "'currency' debe ser un código de moneda ISO 4217", fields[0].Message)
+	})
+
+	main.Run("Struct rules report registered codes", func(t *testing.T) {
+		v := rest.NewValidator()
+		require.NoError(t, v.RegisterCode("rooms_for_guests", "param_not_enough_rooms", "'{0}' must be at least {1}"))
+		v.RegisterStructRule(func(sl rest.StructLevel) {
+			b := sl.Current().Interface().(booking)
+			if b.Rooms*2 < b.Guests {
+				sl.ReportError(b.Rooms, "rooms", "Rooms", "rooms_for_guests", "3")
+			}
+		}, booking{})
+
+		fields := fieldsOf(t, v.Value(reflect.ValueOf(&booking{EndDate: time.Now(), Guests: 5, Rooms: 1})))
+		require.Len(t, fields, 1)
+		require.Equal(t, "rooms", fields[0].Field)
+		require.Equal(t, "param_not_enough_rooms", fields[0].Code)
+		require.Equal(t, "'rooms' must be at least 3", fields[0].Message)
+	})
+}
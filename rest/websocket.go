@@ -0,0 +1,274 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gonzispina/gokit/context"
+	"github.com/gonzispina/gokit/errors"
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// TextMessage denotes a text data message
+	TextMessage = websocket.TextMessage
+	// BinaryMessage denotes a binary data message
+	BinaryMessage = websocket.BinaryMessage
+)
+
+var (
+	errWebSocketUpgradeRequired = errors.New("the request must be a websocket upgrade", "websocket_upgrade_required")
+	errInvalidSubprotocol       = errors.New("none of the requested subprotocols is supported", "websocket_invalid_subprotocol")
+)
+
+// WebSocketConfig of the connections
+type WebSocketConfig struct {
+	// Subprotocols supported by the server in order of preference. When it is not empty
+	// the client must request at least one of them
+	Subprotocols []string
+	// ReadLimit is the maximum size in bytes of a message read from the client, 64KB by default
+	ReadLimit int64
+	// PongWait is the time allowed to read the next message or pong from the client, 60 seconds by default
+	PongWait time.Duration
+	// PingPeriod is the interval between pings, it must be less than PongWait. 9/10 of PongWait by default
+	PingPeriod time.Duration
+	// WriteWait is the time allowed to write a message, 10 seconds by default
+	WriteWait time.Duration
+	// CloseWait is the time allowed to the client to answer the close message before the
+	// connection is closed, 1 second by default
+	CloseWait time.Duration
+	// CheckOrigin returns true if the request Origin header is acceptable.
+	// When it is nil only requests from the same host are accepted
+	CheckOrigin func(r *http.Request) bool
+	// ReadBufferSize and WriteBufferSize of the connection in bytes, 4KB by default
+	ReadBufferSize  int
+	WriteBufferSize int
+}
+
+func (c WebSocketConfig) withDefaults() WebSocketConfig {
+	if c.ReadLimit <= 0 {
+		c.ReadLimit = 64 << 10
+	}
+	if c.PongWait <= 0 {
+		c.PongWait = 60 * time.Second
+	}
+	if c.PingPeriod <= 0 || c.PingPeriod >= c.PongWait {
+		c.PingPeriod = c.PongWait * 9 / 10
+	}
+	if c.WriteWait <= 0 {
+		c.WriteWait = 10 * time.Second
+	}
+	if c.CloseWait <= 0 {
+		c.CloseWait = time.Second
+	}
+	return c
+}
+
+// WebSocket upgrades the request to a websocket connection and calls the handler with it.
+// The middlewares run before the upgrade, so the request can be authenticated as any other
+// through its UserID and IPAddress. The connection is closed when the handler returns and the
+// client is sent a going away close message when the server shuts down.
+// It returns 400 Bad request if the request is not an upgrade or no subprotocol can be negotiated
+func WebSocket(config WebSocketConfig, handler func(r *Request, conn *Conn)) HandlerFunc {
+	config = config.withDefaults()
+	return func(r *Request) *Response {
+		if !websocket.IsWebSocketUpgrade(r.Request) {
+			return BadRequest(errWebSocketUpgradeRequired)
+		}
+		if len(config.Subprotocols) > 0 && !supportsSubprotocol(config.Subprotocols, websocket.Subprotocols(r.Request)) {
+			return BadRequest(errInvalidSubprotocol)
+		}
+		return NewResponse(http.StatusSwitchingProtocols, &webSocket{config: config, handler: handler}, nil)
+	}
+}
+
+func supportsSubprotocol(supported, requested []string) bool {
+	for _, s := range supported {
+		for _, r := range requested {
+			if s == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type webSocket struct {
+	config  WebSocketConfig
+	handler func(r *Request, conn *Conn)
+}
+
+// Stream upgrades the connection and runs the handler
+func (s *webSocket) Stream(w http.ResponseWriter, r *Request, logger logs.Logger) {
+	upgrader := websocket.Upgrader{
+		Subprotocols:    s.config.Subprotocols,
+		CheckOrigin:     s.config.CheckOrigin,
+		ReadBufferSize:  s.config.ReadBufferSize,
+		WriteBufferSize: s.config.WriteBufferSize,
+	}
+
+	ctx := r.Context()
+	ws, err := upgrader.Upgrade(w, r.Request, nil)
+	if err != nil {
+		// The upgrader already replied with an error
+		logger.Warn(ctx, "Couldn't upgrade to websocket", logs.Error(err), logs.UserID(r.UserID))
+		return
+	}
+
+	conn := newConn(ctx, ws, s.config, logger)
+	logger.Debug(ctx, "Websocket connection opened", logs.UserID(r.UserID))
+	defer func() {
+		conn.shutdown()
+		logger.Debug(ctx, "Websocket connection closed", logs.UserID(r.UserID))
+	}()
+
+	go conn.ping(ctx)
+	s.handler(r, conn)
+}
+
+// Conn is a websocket connection. Reads must be done from a single goroutine
+// and the pongs of the client are only processed while reading. Writes are safe to do concurrently
+type Conn struct {
+	ws     *websocket.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
+	config WebSocketConfig
+	logger logs.Logger
+	mu     sync.Mutex
+	closed bool
+	// readFailed is set when a read fails, after which nothing else can be read
+	readFailed int32
+}
+
+func newConn(ctx context.Context, ws *websocket.Conn, config WebSocketConfig, logger logs.Logger) *Conn {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &Conn{ws: ws, ctx: ctx, cancel: cancel, config: config, logger: logger}
+
+	ws.SetReadLimit(config.ReadLimit)
+	_ = ws.SetReadDeadline(time.Now().Add(config.PongWait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(config.PongWait))
+	})
+	return c
+}
+
+// Context of the connection. It keeps the tracking ID of the request and
+// it is cancelled when the connection is lost or closed, or the server shuts down
+func (c *Conn) Context() context.Context {
+	return c.ctx
+}
+
+// Subprotocol negotiated with the client
+func (c *Conn) Subprotocol() string {
+	return c.ws.Subprotocol()
+}
+
+// ReadMessage blocks until a message is received. Any error means that the connection is no longer usable
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	messageType, data, err = c.ws.ReadMessage()
+	if err != nil {
+		atomic.StoreInt32(&c.readFailed, 1)
+		c.cancel()
+		if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+			c.logger.Warn(c.ctx, "Websocket connection lost", logs.Error(err))
+		}
+		return 0, nil, err
+	}
+	_ = c.ws.SetReadDeadline(time.Now().Add(c.config.PongWait))
+	return messageType, data, nil
+}
+
+// ReadJSON reads the next message and decodes it into v
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage sends a message of the given type
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.ws.SetWriteDeadline(time.Now().Add(c.config.WriteWait))
+	if err := c.ws.WriteMessage(messageType, data); err != nil {
+		c.cancel()
+		return err
+	}
+	return nil
+}
+
+// WriteJSON sends v encoded as JSON in a text message
+func (c *Conn) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.ws.SetWriteDeadline(time.Now().Add(c.config.WriteWait))
+	if err := c.ws.WriteJSON(v); err != nil {
+		c.cancel()
+		return err
+	}
+	return nil
+}
+
+// Close sends a close message with the given code and reason to the client.
+// The connection is closed once the handler returns and the client answers
+// the close message, or CloseWait passes
+func (c *Conn) Close(code int, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.cancel()
+	return c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(c.config.WriteWait))
+}
+
+// ping the client until the connection is closed. The client is asked to close
+// the connection when the request context is cancelled, i.e. the server shuts down
+func (c *Conn) ping(ctx context.Context) {
+	ticker := time.NewTicker(c.config.PingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			if ctx.Err() != nil {
+				_ = c.Close(websocket.CloseGoingAway, "server shutting down")
+			}
+			return
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.config.WriteWait)); err != nil {
+				c.logger.Warn(c.ctx, "Couldn't ping websocket client", logs.Error(err))
+				c.cancel()
+				return
+			}
+		}
+	}
+}
+
+// shutdown closes the connection gracefully if the handler didn't
+func (c *Conn) shutdown() {
+	_ = c.Close(websocket.CloseNormalClosure, "")
+	if atomic.LoadInt32(&c.readFailed) == 0 {
+		c.awaitClose()
+	}
+	if err := c.ws.Close(); err != nil {
+		c.logger.Warn(c.ctx, "Couldn't close websocket connection", logs.Error(err))
+	}
+}
+
+// awaitClose discards the messages of the client until it answers the close message or CloseWait passes
+func (c *Conn) awaitClose() {
+	c.ws.SetPongHandler(nil)
+	_ = c.ws.SetReadDeadline(time.Now().Add(c.config.CloseWait))
+	for {
+		if _, _, err := c.ws.NextReader(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gonzispina/gokit/logs"
+	"github.com/gonzispina/gokit/rest"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebSocket(main *testing.T) {
+	logger := logs.InitTest()
+
+	router := rest.NewRouter(logger)
+	router.Get("/orders/live", rest.WebSocket(rest.WebSocketConfig{Subprotocols: []string{"orders.v1"}, ReadLimit: 128}, func(r *rest.Request, conn *rest.Conn) {
+		for {
+			var message map[string]string
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+			message["user"] = r.UserID
+			message["protocol"] = conn.Subprotocol()
+			if err := conn.WriteJSON(message); err != nil {
+				return
+			}
+		}
+	}))
+
+	router.Get("/orders/done", rest.WebSocket(rest.WebSocketConfig{CloseWait: 200 * time.Millisecond}, func(r *rest.Request, conn *rest.Conn) {
+		_ = conn.Close(websocket.CloseNormalClosure, "done")
+	}))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	base := "ws" + strings.TrimPrefix(server.URL, "http")
+	url := base + "/orders/live"
+
+	main.Run("Messages are exchanged over the negotiated subprotocol", func(t *testing.T) {
+		header := http.Header{rest.CallerIDHeader: {"user-1"}}
+		dialer := websocket.Dialer{Subprotocols: []string{"orders.v2", "orders.v1"}}
+		conn, res, err := dialer.Dial(url, header)
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Equal(t, "orders.v1", res.Header.Get(rest.SecWebProtocolHeader))
+
+		require.NoError(t, conn.WriteJSON(map[string]string{"orderId": "1"}))
+		var message map[string]string
+		require.NoError(t, conn.ReadJSON(&message))
+		require.Equal(t, map[string]string{"orderId": "1", "user": "user-1", "protocol": "orders.v1"}, message)
+	})
+
+	main.Run("Messages over the read limit close the connection", func(t *testing.T) {
+		dialer := websocket.Dialer{Subprotocols: []string{"orders.v1"}}
+		conn, _, err := dialer.Dial(url, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("a", 256))))
+		_, _, err = conn.ReadMessage()
+		require.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig))
+	})
+
+	main.Run("Requests without a supported subprotocol are rejected", func(t *testing.T) {
+		dialer := websocket.Dialer{Subprotocols: []string{"orders.v2"}}
+		_, res, err := dialer.Dial(url, nil)
+		require.Error(t, err)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, err = http.Get(server.URL + "/orders/live")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	main.Run("The connection is closed once the client answers the close message", func(t *testing.T) {
+		// closedAfter returns how long the server keeps the connection after sending the close message
+		closedAfter := func(answer bool) time.Duration {
+			conn, _, err := websocket.DefaultDialer.Dial(base+"/orders/done", nil)
+			require.NoError(t, err)
+			defer conn.Close()
+			if !answer {
+				conn.SetCloseHandler(func(int, string) error { return nil })
+			}
+
+			_, _, err = conn.ReadMessage()
+			require.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure))
+			start := time.Now()
+			_, err = conn.UnderlyingConn().Read(make([]byte, 1))
+			require.Error(t, err)
+			return time.Since(start)
+		}
+
+		require.Less(t, closedAfter(true), 100*time.Millisecond)
+		require.GreaterOrEqual(t, closedAfter(false), 150*time.Millisecond)
+	})
+}